@@ -59,8 +59,8 @@ var configShowCmd = &cobra.Command{
 				fmt.Printf("    connection: %s\n", maskConnection(app.Connection))
 				if len(app.Queries) > 0 {
 					fmt.Printf("    queries:\n")
-					for label, query := range app.Queries {
-						fmt.Printf("      %s: %s\n", label, query)
+					for _, label := range app.SortedLabels() {
+						fmt.Printf("      %s: %s\n", label, app.Queries[label].Query)
 					}
 				}
 				fmt.Println()
@@ -218,6 +218,10 @@ func maskConnection(conn string) string {
 
 				if strings.Contains(userPass, ":") {
 					userPassParts := strings.SplitN(userPass, ":", 2)
+					// ${VAR} references are templates, not secrets
+					if config.IsEnvReference(userPassParts[1]) {
+						return conn
+					}
 					return fmt.Sprintf("%s://%s:***@%s", scheme, userPassParts[0], hostDb)
 				}
 			}
@@ -226,7 +230,7 @@ func maskConnection(conn string) string {
 		parts := strings.Split(conn, "@")
 		if len(parts) > 1 {
 			userPass := strings.Split(parts[0], ":")
-			if len(userPass) > 1 {
+			if len(userPass) > 1 && !config.IsEnvReference(strings.Join(userPass[1:], ":")) {
 				return userPass[0] + ":***@" + strings.Join(parts[1:], "@")
 			}
 		}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/lucasnevespereira/dashmin/internal/history"
+	"github.com/spf13/cobra"
+)
+
+const chartWidth = 40
+
+var (
+	historySince string
+	historyKeep  string
+	historyChart bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <app> <label>",
+	Short: "Show past values of a query",
+	Long: `Show the values recorded for a query by the dashboard and by
+dashmin show --output. History is kept in a local SQLite file next to the
+config file.
+
+Durations accept s, m, h, d (days) and w (weeks).
+
+Examples:
+  dashmin history myapp users                # Last 24 hours
+  dashmin history myapp users --since 7d     # Last week
+  dashmin history myapp users --chart        # Bar chart
+  dashmin history --keep 90d                 # Delete samples older than 90 days`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if historyKeep != "" && len(args) == 0 {
+			return nil
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := history.Open(history.DefaultPath())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = store.Close() }()
+
+		if historyKeep != "" {
+			keep, err := config.ParseAge(historyKeep)
+			if err != nil {
+				return fmt.Errorf("--keep: %w", err)
+			}
+			removed, err := store.Prune(time.Now().Add(-keep))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed %d sample(s) older than %s\n", removed, historyKeep)
+			if len(args) == 0 {
+				return nil
+			}
+			fmt.Println()
+		}
+
+		since, err := config.ParseAge(historySince)
+		if err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+
+		appName, label := args[0], args[1]
+		samples, err := store.Samples(appName, label, time.Now().Add(-since))
+		if err != nil {
+			return err
+		}
+
+		if len(samples) == 0 {
+			fmt.Printf("No history for '%s.%s' in the last %s.\n", appName, label, historySince)
+			fmt.Printf("\nHistory is recorded while the dashboard runs and by:\n")
+			fmt.Printf("  dashmin show %s --output json\n", appName)
+			return nil
+		}
+
+		fmt.Printf("History for %s.%s (%d samples since %s)\n\n", appName, label, len(samples), samples[0].RecordedAt.Format("2006-01-02 15:04"))
+		if historyChart {
+			printHistoryChart(samples)
+		} else {
+			printHistoryTable(samples)
+		}
+		return nil
+	},
+}
+
+func printHistoryTable(samples []history.Sample) {
+	fmt.Printf("%-20s %-20s %s\n", "TIME", "VALUE", "DURATION")
+	fmt.Printf("%s\n", strings.Repeat("-", 52))
+	for _, s := range samples {
+		value := s.Text
+		if s.Error != "" {
+			value = "ERROR: " + s.Error
+		}
+		fmt.Printf("%-20s %-20s %s\n", s.RecordedAt.Format("2006-01-02 15:04:05"), value, s.Duration.Round(time.Microsecond))
+	}
+}
+
+// printHistoryChart draws one horizontal bar per numeric sample, scaled
+// between the smallest and largest value
+func printHistoryChart(samples []history.Sample) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range samples {
+		if s.Value != nil {
+			lo = math.Min(lo, *s.Value)
+			hi = math.Max(hi, *s.Value)
+		}
+	}
+	if math.IsInf(lo, 1) {
+		fmt.Printf("No numeric values to chart.\n")
+		return
+	}
+	// Start bars at zero unless values are negative
+	lo = math.Min(lo, 0)
+
+	for _, s := range samples {
+		timestamp := s.RecordedAt.Format("01-02 15:04")
+		switch {
+		case s.Error != "":
+			fmt.Printf("%s  %s\n", timestamp, "ERROR")
+		case s.Value == nil:
+			fmt.Printf("%s  %s\n", timestamp, s.Text)
+		default:
+			width := chartWidth
+			if hi > lo {
+				width = int(math.Round((*s.Value - lo) / (hi - lo) * chartWidth))
+			}
+			fmt.Printf("%s  %-*s %s\n", timestamp, chartWidth, strings.Repeat("█", width), s.Text)
+		}
+	}
+}
+
+// recordHistory stores results in the history database. Failures only
+// produce a warning since history is secondary to the command's output.
+func recordHistory(results []collector.QueryResult) {
+	store, err := history.Open(history.DefaultPath())
+	if err == nil {
+		defer func() { _ = store.Close() }()
+		err = store.Record(results)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historySince, "since", "24h", "How far back to look (e.g. 6h, 7d, 2w)")
+	historyCmd.Flags().StringVar(&historyKeep, "keep", "", "Delete samples older than this (e.g. 90d)")
+	historyCmd.Flags().BoolVar(&historyChart, "chart", false, "Draw a bar chart instead of a table")
+}
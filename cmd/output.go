@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/db"
+	"gopkg.in/yaml.v3"
+)
+
+var outputFormats = []string{"json", "yaml", "csv", "table"}
+
+// outputResult is the machine-readable form of a query result
+type outputResult struct {
+	App        string          `json:"app" yaml:"app"`
+	Query      string          `json:"query" yaml:"query"`
+	Columns    []string        `json:"columns" yaml:"columns"`
+	Rows       [][]interface{} `json:"rows" yaml:"rows"`
+	Error      string          `json:"error,omitempty" yaml:"error,omitempty"`
+	Previous   *outputPrevious `json:"previous,omitempty" yaml:"previous,omitempty"`
+	DurationMs float64         `json:"duration_ms" yaml:"duration_ms"`
+	Timestamp  time.Time       `json:"timestamp" yaml:"timestamp"`
+}
+
+// outputPrevious is the result of a query's comparison with a previous
+// period
+type outputPrevious struct {
+	Columns []string        `json:"columns" yaml:"columns"`
+	Rows    [][]interface{} `json:"rows" yaml:"rows"`
+	Error   string          `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func validateOutputFormat(format string) error {
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output format '%s'. Supported: %s", format, strings.Join(outputFormats, ", "))
+}
+
+func toOutputResults(results []collector.QueryResult) []outputResult {
+	out := make([]outputResult, 0, len(results))
+	for _, r := range results {
+		o := outputResult{
+			App:        r.AppName,
+			Query:      r.QueryLabel,
+			Columns:    r.Result.Columns,
+			Rows:       r.Result.Rows,
+			DurationMs: float64(r.Duration.Microseconds()) / 1000,
+			Timestamp:  r.LastUpdated,
+		}
+		if o.Columns == nil {
+			o.Columns = []string{}
+		}
+		if o.Rows == nil {
+			o.Rows = [][]interface{}{}
+		}
+		if r.Result.Error != nil {
+			o.Error = r.Result.Error.Error()
+		}
+		if r.Previous != nil {
+			o.Previous = &outputPrevious{Columns: r.Previous.Columns, Rows: r.Previous.Rows}
+			if o.Previous.Columns == nil {
+				o.Previous.Columns = []string{}
+			}
+			if o.Previous.Rows == nil {
+				o.Previous.Rows = [][]interface{}{}
+			}
+			if r.Previous.Error != nil {
+				o.Previous.Error = r.Previous.Error.Error()
+			}
+		}
+		out = append(out, o)
+	}
+	return out
+}
+
+// writeResults prints results in the given format
+func writeResults(w io.Writer, format string, results []collector.QueryResult) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(toOutputResults(results))
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(toOutputResults(results)); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "csv":
+		return writeCSV(w, results)
+	case "table":
+		for i, r := range results {
+			if i > 0 {
+				_, _ = fmt.Fprintln(w)
+			}
+			_, _ = fmt.Fprintf(w, "%s.%s (%s, %s)\n", r.AppName, r.QueryLabel, r.Duration.Round(time.Microsecond), r.LastUpdated.Format(time.RFC3339))
+			if r.Result.Error != nil {
+				_, _ = fmt.Fprintf(w, "Error: %v\n", r.Result.Error)
+				continue
+			}
+			fprintResultTable(w, r.Result, 0)
+			if r.Previous != nil {
+				_, _ = fmt.Fprintf(w, "Previous (%s):\n", r.Query.Compare)
+				if r.Previous.Error != nil {
+					_, _ = fmt.Fprintf(w, "Error: %v\n", r.Previous.Error)
+					continue
+				}
+				fprintResultTable(w, r.Previous, 0)
+			}
+		}
+		return nil
+	default:
+		return validateOutputFormat(format)
+	}
+}
+
+// writeCSV writes one line per cell so results with different columns fit
+// in a single table
+func writeCSV(w io.Writer, results []collector.QueryResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"app", "query", "row", "column", "value", "error", "duration_ms", "timestamp"}); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		duration := strconv.FormatFloat(float64(r.Duration.Microseconds())/1000, 'f', -1, 64)
+		timestamp := r.LastUpdated.Format(time.RFC3339)
+
+		if r.Result.Error != nil || len(r.Result.Rows) == 0 {
+			errStr := ""
+			if r.Result.Error != nil {
+				errStr = r.Result.Error.Error()
+			}
+			if err := cw.Write([]string{r.AppName, r.QueryLabel, "", "", "", errStr, duration, timestamp}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for i, row := range r.Result.Rows {
+			for j, val := range row {
+				column := ""
+				if j < len(r.Result.Columns) {
+					column = r.Result.Columns[j]
+				}
+				record := []string{r.AppName, r.QueryLabel, strconv.Itoa(i), column, fmt.Sprintf("%v", val), "", duration, timestamp}
+				if err := cw.Write(record); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// printResultTable prints a result as a simple table on stdout. A maxRows of
+// zero prints every row.
+func printResultTable(result *db.Result, maxRows int) {
+	fprintResultTable(os.Stdout, result, maxRows)
+}
+
+func fprintResultTable(w io.Writer, result *db.Result, maxRows int) {
+	if len(result.Rows) == 0 {
+		_, _ = fmt.Fprintf(w, "  No results\n")
+		return
+	}
+
+	for i, col := range result.Columns {
+		if i > 0 {
+			_, _ = fmt.Fprintf(w, " | ")
+		}
+		_, _ = fmt.Fprintf(w, "%s", col)
+	}
+	_, _ = fmt.Fprintf(w, "\n")
+
+	for i, col := range result.Columns {
+		if i > 0 {
+			_, _ = fmt.Fprintf(w, "-+-")
+		}
+		_, _ = fmt.Fprintf(w, "%s", strings.Repeat("-", len(col)))
+	}
+	_, _ = fmt.Fprintf(w, "\n")
+
+	for i, row := range result.Rows {
+		if maxRows > 0 && i >= maxRows {
+			_, _ = fmt.Fprintf(w, "... (%d more rows)\n", len(result.Rows)-maxRows)
+			break
+		}
+
+		for j, val := range row {
+			if j > 0 {
+				_, _ = fmt.Fprintf(w, " | ")
+			}
+			_, _ = fmt.Fprintf(w, "%v", val)
+		}
+		_, _ = fmt.Fprintf(w, "\n")
+	}
+}
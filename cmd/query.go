@@ -2,13 +2,18 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/lucasnevespereira/dashmin/internal/ai"
+	"github.com/lucasnevespereira/dashmin/internal/collector"
 	"github.com/lucasnevespereira/dashmin/internal/config"
 	"github.com/lucasnevespereira/dashmin/internal/db"
+	"github.com/lucasnevespereira/dashmin/internal/templates"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +22,24 @@ var (
 	executeFlag  bool
 	forceFlag    bool
 	queryYesFlag bool
+
+	queryDescription string
+	queryUnit        string
+	queryFormat      string
+	queryWarn        string
+	queryCritical    string
+	queryTimeout     time.Duration
+	queryInterval    time.Duration
+	queryCompare     string
+	queryOrder       int
+	queryDisplay     string
+	queryGroup       string
+	querySize        string
+
+	queryTemplate   string
+	queryTable      string
+	queryDateColumn string
+	queryColumn     string
 )
 
 var queryCmd = &cobra.Command{
@@ -26,28 +49,77 @@ var queryCmd = &cobra.Command{
 
 Examples:
   dashmin query add myapp users "SELECT COUNT(*) FROM users"
+  dashmin query add myapp signups --template count-today --table users --date-column created_at
   dashmin query list myapp
   dashmin query remove myapp users
+  dashmin query templates
   dashmin query generate myapp "users who signed up today"`,
 }
 
 var queryAddCmd = &cobra.Command{
-	Use:   "add <app> <label> <query>",
+	Use:   "add <app> <label> [query]",
 	Short: "Add a custom query to an app",
 	Long: `Add a custom query to monitor specific metrics for an app.
 
+Instead of writing the query, you can generate it from a template with
+--template. See the available templates with: dashmin query templates
+
 Examples:
   dashmin query add myapp users "SELECT COUNT(*) FROM users"
   dashmin query add myapp posts "SELECT COUNT(*) FROM posts WHERE created_at > NOW() - INTERVAL '30 days'"
   dashmin query add webapp revenue "SELECT SUM(amount) FROM payments WHERE DATE(created_at) = CURDATE()"
   dashmin query add analytics active_users "users.count({\"status\": \"active\"})"
+  dashmin query add analytics revenue "orders.aggregate([{\"$group\": {\"_id\": null, \"total\": {\"$sum\": \"$amount\"}}}, {\"$project\": {\"_id\": 0, \"total\": 1}}])"
+  dashmin query add myapp errors "SELECT COUNT(*) FROM logs WHERE level='error'" --warn 10 --critical 50
+  dashmin query add myapp signups "SELECT COUNT(*) FROM users WHERE created_at >= CURRENT_DATE" --warn "< 5"
+  dashmin query add myapp db_size "SELECT pg_database_size(current_database())" --format bytes
+  dashmin query add myapp top_errors "SELECT path, COUNT(*) FROM errors GROUP BY path ORDER BY 2 DESC LIMIT 5" --display table
+  dashmin query add myapp total_users "SELECT COUNT(*) FROM users" --interval 10m
+  dashmin query add myapp signups "SELECT COUNT(*) FROM users WHERE created_at >= CURRENT_DATE AND created_at < NOW()" --compare yesterday
+  dashmin query add myapp users --template count --table users
+  dashmin query add myapp signups --template count-today --table users --date-column created_at --compare yesterday
+  dashmin query add myapp revenue --template sum-today --table orders --column amount --date-column created_at
 
 The query will be validated against the database. Use --force to skip validation.`,
-	Args: cobra.ExactArgs(3),
+	Args: cobra.RangeArgs(2, 3),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		appName := args[0]
 		label := args[1]
-		query := args[2]
+
+		var tmpl templates.Template
+		params := templates.Params{Table: queryTable, DateColumn: queryDateColumn, Column: queryColumn}
+		if queryTemplate != "" {
+			if len(args) == 3 {
+				return fmt.Errorf("use either a query or --template, not both")
+			}
+			var err error
+			if tmpl, err = templates.Get(queryTemplate); err != nil {
+				return err
+			}
+		} else if len(args) < 3 {
+			return fmt.Errorf("missing query: pass it as the third argument or use --template")
+		}
+
+		if err := config.ValidateFormat(queryFormat); err != nil {
+			return err
+		}
+		if err := config.ValidateDisplay(queryDisplay); err != nil {
+			return err
+		}
+		if err := config.ValidateSize(querySize); err != nil {
+			return err
+		}
+		if queryInterval < 0 {
+			return fmt.Errorf("invalid interval %s: must be positive", queryInterval)
+		}
+		warn, err := parseThresholdFlag(queryWarn)
+		if err != nil {
+			return fmt.Errorf("--warn: %w", err)
+		}
+		critical, err := parseThresholdFlag(queryCritical)
+		if err != nil {
+			return fmt.Errorf("--critical: %w", err)
+		}
 
 		cfg, err := config.Load()
 		if err != nil {
@@ -60,36 +132,115 @@ The query will be validated against the database. Use --force to skip validation
 		}
 
 		if app.Queries == nil {
-			app.Queries = make(map[string]string)
+			app.Queries = make(map[string]config.Query)
+		}
+
+		var query string
+		description := queryDescription
+		if queryTemplate != "" {
+			var summary string
+			if query, summary, err = tmpl.Render(app.Type, params); err != nil {
+				return err
+			}
+			if description == "" {
+				description = summary
+			}
+		} else {
+			query = args[2]
 		}
 
 		if existing, exists := app.Queries[label]; exists {
 			fmt.Printf("Warning: Overwriting existing query '%s'\n", label)
-			fmt.Printf("  Old: %s\n", existing)
+			fmt.Printf("  Old: %s\n", existing.Query)
 			fmt.Printf("  New: %s\n\n", query)
 		}
 
 		// Validate query unless --force is used
 		if !forceFlag {
 			fmt.Printf("Validating query...\n")
-			conn, err := db.ConnectByType(app.Type, app.Connection)
+			conn, err := collector.Connect(app)
 			if err != nil {
 				return fmt.Errorf("connecting to database for validation: %w", err)
 			}
 			defer func() { _ = conn.Close() }()
 
-			_, err = conn.Query(query)
+			if queryTemplate != "" {
+				if err := checkTemplateSchema(conn, app.Type, tmpl, params); err != nil {
+					fmt.Printf("\n✗ Template check failed:\n")
+					fmt.Printf("  Error: %v\n\n", err)
+					fmt.Printf("The query was not added.\n")
+					return fmt.Errorf("query validation failed")
+				}
+			}
+
+			expanded, err := config.ExpandEnv(query)
+			if err != nil {
+				return fmt.Errorf("app '%s': query '%s': %w", appName, label, err)
+			}
+
+			resolved, err := app.WithVars(cfg.Vars).Resolve()
+			if err != nil {
+				return err
+			}
+			macros := resolved.Macros(time.Now())
+
+			timeout, source := collector.Timeout(app, config.Query{Timeout: queryTimeout})
+			rendered, err := macros.Render(expanded)
+			if err == nil {
+				var result *db.Result
+				result, err = db.QueryWithTimeout(cmd.Context(), conn, rendered, timeout, source)
+				if err == nil {
+					err = result.Error
+				}
+			}
 			if err != nil {
 				fmt.Printf("\n✗ Query validation failed:\n")
 				fmt.Printf("  Error: %v\n\n", err)
 				fmt.Printf("The query was not added.\n")
-				fmt.Printf("To add it anyway, use: dashmin query add %s %s \"%s\" --force\n", appName, label, query)
+				if queryTemplate != "" {
+					fmt.Printf("To add it anyway, add --force to the command\n")
+				} else {
+					fmt.Printf("To add it anyway, use: dashmin query add %s %s \"%s\" --force\n", appName, label, query)
+				}
 				return fmt.Errorf("query validation failed")
 			}
 			fmt.Printf("✓ Query validated successfully\n\n")
+
+			if queryCompare != "" {
+				compare := config.Query{Query: expanded, Compare: queryCompare}
+				if _, period := compare.CompareDays(); !period {
+					if compare.Compare, err = config.ExpandEnv(queryCompare); err != nil {
+						return fmt.Errorf("app '%s': query '%s': compare: %w", appName, label, err)
+					}
+				}
+				previous := collector.QueryPrevious(cmd.Context(), conn, macros, compare, timeout, source)
+				if previous.Error != nil {
+					fmt.Printf("✗ Comparison with %s failed:\n", queryCompare)
+					fmt.Printf("  Error: %v\n\n", previous.Error)
+					fmt.Printf("The query was not added.\n")
+					return fmt.Errorf("query validation failed")
+				}
+				fmt.Printf("✓ Comparison validated successfully\n\n")
+			}
 		}
 
-		app.Queries[label] = query
+		q := config.Query{
+			Query:       query,
+			Description: description,
+			Unit:        queryUnit,
+			Format:      queryFormat,
+			Warn:        warn,
+			Critical:    critical,
+			Timeout:     queryTimeout,
+			Interval:    queryInterval,
+			Compare:     queryCompare,
+			Order:       queryOrder,
+			Display:     queryDisplay,
+			Group:       queryGroup,
+			Size:        querySize,
+		}
+
+		app.Queries[label] = q
 		cfg.Apps[appName] = app
 
 		if err := cfg.Save(); err != nil {
@@ -131,7 +282,7 @@ Examples:
 			return fmt.Errorf("app '%s' has no queries", appName)
 		}
 
-		querySQL, queryExists := app.Queries[label]
+		existing, queryExists := app.Queries[label]
 		if !queryExists {
 			fmt.Printf("Error: Query '%s' not found in app '%s'.\n", label, appName)
 			if len(app.Queries) > 0 {
@@ -161,7 +312,7 @@ Examples:
 		}
 
 		fmt.Printf("Removed query '%s' from app '%s'\n", label, appName)
-		fmt.Printf("Query was: %s\n", querySQL)
+		fmt.Printf("Query was: %s\n", existing.Query)
 		return nil
 	},
 }
@@ -196,9 +347,8 @@ Examples:
 
 		fmt.Printf("Queries for '%s' (%d):\n\n", appName, len(app.Queries))
 
-		for label, query := range app.Queries {
-			fmt.Printf("  %s\n", label)
-			fmt.Printf("    %s\n\n", query)
+		for _, label := range app.SortedLabels() {
+			printQuery(label, app.Queries[label])
 		}
 		return nil
 	},
@@ -238,7 +388,7 @@ Examples:
 		}
 
 		fmt.Printf("Analyzing database schema...\n")
-		conn, err := db.ConnectByType(app.Type, app.Connection)
+		conn, err := collector.Connect(app)
 		if err != nil {
 			return fmt.Errorf("connecting to database: %w", err)
 		}
@@ -277,7 +427,7 @@ Examples:
 		}
 
 		if executeFlag {
-			executeGeneratedQuery(conn, response.SQL)
+			executeGeneratedQuery(cmd.Context(), conn, response.SQL)
 		}
 		if saveFlag {
 			saveGeneratedQuery(cfg, appName, response.SQL, prompt)
@@ -286,6 +436,57 @@ Examples:
 	},
 }
 
+var queryTemplatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "List the query templates",
+	Long: `List the templates available to 'dashmin query add --template'.
+
+Besides the built-in templates, any YAML file in the templates directory
+next to the config file is loaded as a template. A user template replaces
+the built-in template of the same name.
+
+Examples:
+  dashmin query templates
+  dashmin query add myapp signups --template count-today --table users --date-column created_at`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := templates.List()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Query templates (%d):\n\n", len(list))
+		for _, t := range list {
+			fmt.Printf("  %s\n", t.Name)
+			if t.Description != "" {
+				fmt.Printf("    %s\n", t.Summary())
+			}
+			settings := []string{"databases: " + strings.Join(t.Types(), ", ")}
+			if len(t.Params) > 0 {
+				settings = append([]string{"flags: " + strings.Join(t.Flags(), " ")}, settings...)
+			}
+			fmt.Printf("    %s\n", strings.Join(settings, ", "))
+			if t.Path != "" {
+				fmt.Printf("    file: %s\n", t.Path)
+			}
+			fmt.Printf("\n")
+		}
+		fmt.Printf("Add your own templates as YAML files in %s\n", templates.Dir())
+		return nil
+	},
+}
+
+// checkTemplateSchema verifies that the tables and columns given to a
+// template exist. A schema that cannot be read only prints a warning.
+func checkTemplateSchema(conn db.Connection, dbType string, tmpl templates.Template, params templates.Params) error {
+	schema, err := db.GetSchema(conn, dbType)
+	if err != nil {
+		fmt.Printf("Warning: Could not retrieve schema: %v\n", err)
+		return nil
+	}
+	return tmpl.CheckSchema(schema, params)
+}
+
 func appNotFoundError(appName string, cfg *config.Config) error {
 	fmt.Printf("Error: App '%s' not found.\n", appName)
 	if len(cfg.Apps) > 0 {
@@ -298,10 +499,65 @@ func appNotFoundError(appName string, cfg *config.Config) error {
 	return fmt.Errorf("app '%s' not found", appName)
 }
 
-func executeGeneratedQuery(conn db.Connection, query string) {
+// printQuery prints a query and whichever optional settings it has
+func printQuery(label string, q config.Query) {
+	fmt.Printf("  %s\n", label)
+	fmt.Printf("    %s\n", q.Query)
+	if q.Description != "" {
+		fmt.Printf("    description: %s\n", q.Description)
+	}
+
+	var settings []string
+	if q.Unit != "" {
+		settings = append(settings, "unit: "+q.Unit)
+	}
+	if q.Format != "" {
+		settings = append(settings, "format: "+q.Format)
+	}
+	if q.Warn != nil {
+		settings = append(settings, "warn: "+q.Warn.String())
+	}
+	if q.Critical != nil {
+		settings = append(settings, "critical: "+q.Critical.String())
+	}
+	if q.Timeout > 0 {
+		settings = append(settings, "timeout: "+q.Timeout.String())
+	}
+	if q.Interval > 0 {
+		settings = append(settings, "interval: "+q.Interval.String())
+	}
+	if q.Compare != "" {
+		settings = append(settings, "compare: "+q.Compare)
+	}
+	if q.Order != 0 {
+		settings = append(settings, "order: "+strconv.Itoa(q.Order))
+	}
+	if q.Display != "" {
+		settings = append(settings, "display: "+q.Display)
+	}
+	if q.Group != "" {
+		settings = append(settings, "group: "+q.Group)
+	}
+	if q.Size != "" {
+		settings = append(settings, "size: "+q.Size)
+	}
+	if len(settings) > 0 {
+		fmt.Printf("    %s\n", strings.Join(settings, ", "))
+	}
+	fmt.Printf("\n")
+}
+
+func parseThresholdFlag(value string) (*config.Threshold, error) {
+	if value == "" {
+		return nil, nil
+	}
+	return config.ParseThreshold(value)
+}
+
+func executeGeneratedQuery(ctx context.Context, conn db.Connection, query string) {
 	fmt.Printf("\nExecuting query...\n")
 
-	result, err := conn.Query(query)
+	result, err := db.QueryWithTimeout(ctx, conn, query, db.DefaultTimeout, "default timeout")
 	if err != nil {
 		fmt.Printf("Execution error: %v\n", err)
 		return
@@ -319,37 +575,7 @@ func executeGeneratedQuery(conn db.Connection, query string) {
 		return
 	}
 
-	for i, col := range result.Columns {
-		if i > 0 {
-			fmt.Printf(" | ")
-		}
-		fmt.Printf("%s", col)
-	}
-	fmt.Printf("\n")
-
-	for i, col := range result.Columns {
-		if i > 0 {
-			fmt.Printf("-+-")
-		}
-		fmt.Printf("%s", strings.Repeat("-", len(col)))
-	}
-	fmt.Printf("\n")
-
-	maxRows := 10
-	for i, row := range result.Rows {
-		if i >= maxRows {
-			fmt.Printf("... (%d more rows)\n", len(result.Rows)-maxRows)
-			break
-		}
-
-		for j, val := range row {
-			if j > 0 {
-				fmt.Printf(" | ")
-			}
-			fmt.Printf("%v", val)
-		}
-		fmt.Printf("\n")
-	}
+	printResultTable(result, 10)
 }
 
 func saveGeneratedQuery(cfg *config.Config, appName, query, prompt string) {
@@ -366,9 +592,9 @@ func saveGeneratedQuery(cfg *config.Config, appName, query, prompt string) {
 
 	app := cfg.Apps[appName]
 	if app.Queries == nil {
-		app.Queries = make(map[string]string)
+		app.Queries = make(map[string]config.Query)
 	}
-	app.Queries[label] = query
+	app.Queries[label] = config.Query{Query: query, Description: prompt}
 	cfg.Apps[appName] = app
 
 	if err := cfg.Save(); err != nil {
@@ -383,6 +609,22 @@ func saveGeneratedQuery(cfg *config.Config, appName, query, prompt string) {
 
 func init() {
 	queryAddCmd.Flags().BoolVar(&forceFlag, "force", false, "Skip query validation")
+	queryAddCmd.Flags().StringVar(&queryDescription, "description", "", "Describe what the query measures")
+	queryAddCmd.Flags().StringVar(&queryUnit, "unit", "", "Unit shown after the value (e.g. ms, users, €)")
+	queryAddCmd.Flags().StringVar(&queryFormat, "format", "", "Value format: number, percent, bytes, duration or a printf verb like %.1f")
+	queryAddCmd.Flags().StringVar(&queryWarn, "warn", "", "Warning threshold: N (at or above), \"> N\", \"< N\", \"<= N\" or \"outside MIN..MAX\"")
+	queryAddCmd.Flags().StringVar(&queryCritical, "critical", "", "Critical threshold, same syntax as --warn")
+	queryAddCmd.Flags().DurationVar(&queryTimeout, "timeout", 0, "Query timeout (e.g. 5s)")
+	queryAddCmd.Flags().StringVar(&queryCompare, "compare", "", "Compare with a previous period: yesterday, last_week or a query returning the previous value")
+	queryAddCmd.Flags().DurationVar(&queryInterval, "interval", 0, "Refresh interval in the dashboard and serve (e.g. 15s, 10m)")
+	queryAddCmd.Flags().IntVar(&queryOrder, "order", 0, "Display position within the app (lower first)")
+	queryAddCmd.Flags().StringVar(&queryDisplay, "display", "", "Dashboard display: value or table (top rows inline)")
+	queryAddCmd.Flags().StringVar(&queryGroup, "group", "", "Group heading for the card in the grid layout")
+	queryAddCmd.Flags().StringVar(&querySize, "size", "", "Card size in the grid layout: small, medium or large")
+	queryAddCmd.Flags().StringVar(&queryTemplate, "template", "", "Generate the query from a template (see: dashmin query templates)")
+	queryAddCmd.Flags().StringVar(&queryTable, "table", "", "Table or collection for --template")
+	queryAddCmd.Flags().StringVar(&queryDateColumn, "date-column", "", "Date column for --template")
+	queryAddCmd.Flags().StringVar(&queryColumn, "column", "", "Value column for --template (e.g. for sum or avg)")
 	queryRemoveCmd.Flags().BoolVarP(&queryYesFlag, "yes", "y", false, "Skip confirmation prompt")
 	queryGenerateCmd.Flags().BoolVar(&saveFlag, "save", false, "Save the generated query")
 	queryGenerateCmd.Flags().BoolVar(&executeFlag, "execute", false, "Execute the generated query immediately")
@@ -391,4 +633,5 @@ func init() {
 	queryCmd.AddCommand(queryRemoveCmd)
 	queryCmd.AddCommand(queryListCmd)
 	queryCmd.AddCommand(queryGenerateCmd)
+	queryCmd.AddCommand(queryTemplatesCmd)
 }
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/lucasnevespereira/dashmin/internal/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+)
+
+var listenFlag string
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [app]",
+	Short: "Expose query results as Prometheus metrics",
+	Long: `Serve every configured query as a Prometheus gauge on /metrics.
+
+Queries run on each scrape, except queries with an interval: these run
+once it has elapsed and report their last result in between. Every query
+produces:
+  dashmin_query_value{app, query, ...}      numeric values returned by the query
+  dashmin_query_success{app, query}         1 if the query succeeded, 0 otherwise
+  dashmin_query_duration_seconds{app, query} histogram of query durations
+
+For queries returning several rows, non-numeric columns become extra labels,
+e.g. "SELECT status, COUNT(*) FROM orders GROUP BY status" produces one
+series per status. With more than one numeric column, a "column" label
+tells them apart.
+
+Examples:
+  dashmin serve                   # Listen on :9187
+  dashmin serve --listen :9200    # Custom address
+  dashmin serve myapp             # Only export myapp`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+
+		appFilter := ""
+		if len(args) == 1 {
+			appFilter = args[0]
+			if _, exists := cfg.Apps[appFilter]; !exists {
+				return appNotFoundError(appFilter, cfg)
+			}
+		}
+
+		conns := collector.NewManager()
+		defer func() { _ = conns.Close() }()
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGTERM)
+		defer stop()
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(exporter.New(ctx, cfg, appFilter, conns))
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+			ErrorHandling: promhttp.ContinueOnError,
+		}))
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/" {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = fmt.Fprintf(w, "dashmin %s\n\nMetrics: /metrics\n", Version)
+		})
+
+		server := &http.Server{
+			Addr:              listenFlag,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- server.ListenAndServe()
+		}()
+		fmt.Printf("Serving metrics on http://%s/metrics\n", displayAddr(listenFlag))
+
+		select {
+		case err := <-errCh:
+			if !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("serving metrics: %w", err)
+			}
+			return nil
+		case <-ctx.Done():
+			fmt.Printf("\nShutting down...\n")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return server.Shutdown(shutdownCtx)
+		}
+	},
+}
+
+// displayAddr turns ":9187" into "localhost:9187" for display
+func displayAddr(addr string) string {
+	if len(addr) > 0 && addr[0] == ':' {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&listenFlag, "listen", ":9187", "Address to listen on")
+}
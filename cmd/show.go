@@ -1,14 +1,28 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"slices"
+	"time"
 
+	"github.com/lucasnevespereira/dashmin/internal/collector"
 	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/lucasnevespereira/dashmin/internal/history"
 	"github.com/lucasnevespereira/dashmin/ui"
 	"github.com/spf13/cobra"
 )
 
+const defaultWatchInterval = 30 * time.Second
+
+var (
+	watchFlag       string
+	outputFlag      string
+	allowErrorsFlag bool
+	layoutFlag      string
+)
+
 var showCmd = &cobra.Command{
 	Use:   "show [app]",
 	Short: "Show the dashboard",
@@ -16,18 +30,68 @@ var showCmd = &cobra.Command{
 
 Without arguments, shows all configured apps.
 With an app name, shows only that specific app.
+With --watch, refreshes automatically (press p to pause). Queries with their
+own "interval:" refresh on that schedule, with or without --watch.
+With --layout grid, shows each app as a panel of metric cards. The default
+layout can be set with "layout:" in the config file.
+With --output, runs every query once and prints the results instead of
+opening the dashboard. The exit code is non-zero if any query failed,
+unless --allow-errors is set.
 
 Examples:
-  dashmin show           # Show all apps
-  dashmin show myapp     # Show specific app`,
-	Args: cobra.MaximumNArgs(1),
+  dashmin show                    # Show all apps
+  dashmin show myapp              # Show specific app
+  dashmin show --watch            # Refresh every 30s
+  dashmin show myapp --watch 10s  # Refresh myapp every 10s
+  dashmin show --layout grid -w   # Status board for a wall monitor
+  dashmin show --output json      # Print all results as JSON
+  dashmin show myapp -o csv       # Print myapp results as CSV`,
+	Args: cobra.MaximumNArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
+		var opts ui.Options
+		if cmd.Flags().Changed("watch") {
+			interval, rest, err := parseWatchInterval(watchFlag, args)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts.RefreshInterval = interval
+			args = rest
+		}
+		if len(args) > 1 {
+			fmt.Printf("Error: accepts at most 1 app, received %d\n", len(args))
+			os.Exit(1)
+		}
+
+		if outputFlag != "" {
+			if opts.RefreshInterval > 0 {
+				fmt.Printf("Error: --watch cannot be combined with --output\n")
+				os.Exit(1)
+			}
+			if err := validateOutputFormat(outputFlag); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
 		cfg, err := config.Load()
 		if err != nil {
 			fmt.Printf("Error loading config: %v\n", err)
 			os.Exit(1)
 		}
 
+		if outputFlag != "" {
+			appFilter := ""
+			if len(args) == 1 {
+				appFilter = args[0]
+				if _, exists := cfg.Apps[appFilter]; !exists {
+					fmt.Fprintf(os.Stderr, "Error: app '%s' not found\n", appFilter)
+					os.Exit(1)
+				}
+			}
+			os.Exit(runOutput(cmd.Context(), cfg, appFilter))
+		}
+
 		if len(cfg.Apps) == 0 {
 			fmt.Println("No apps configured yet.")
 			fmt.Println("\nQuick start:")
@@ -50,9 +114,85 @@ Examples:
 			}
 		}
 
-		if err := ui.RunDashboard(cfg, appFilter); err != nil {
+		if store, err := history.Open(history.DefaultPath()); err == nil {
+			defer func() { _ = store.Close() }()
+			opts.History = store
+		}
+
+		opts.Layout = cfg.Layout
+		if layoutFlag != "" {
+			opts.Layout = layoutFlag
+		}
+		if err := config.ValidateLayout(opts.Layout); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := ui.RunDashboard(cmd.Context(), cfg, appFilter, opts); err != nil {
 			fmt.Printf("Error running dashboard: %v\n", err)
 			os.Exit(1)
 		}
 	},
 }
+
+// runOutput collects every query once and prints the results. It returns
+// the process exit code.
+func runOutput(ctx context.Context, cfg *config.Config, appFilter string) int {
+	conns := collector.NewManager()
+	defer func() { _ = conns.Close() }()
+
+	results, err := collector.Collect(ctx, cfg, appFilter, conns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	recordHistory(results)
+
+	if err := writeResults(os.Stdout, outputFlag, results); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return 1
+	}
+
+	if collector.HasErrors(results) && !allowErrorsFlag {
+		return 1
+	}
+	return 0
+}
+
+// parseWatchInterval resolves the --watch interval. The interval may follow
+// the flag as a separate argument (--watch 10s), in which case it is removed
+// from the positional args wherever it ended up, before or after the app.
+func parseWatchInterval(flagValue string, args []string) (time.Duration, []string, error) {
+	interval := defaultWatchInterval
+	if flagValue != "" {
+		d, err := time.ParseDuration(flagValue)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid watch interval '%s': %w", flagValue, err)
+		}
+		interval = d
+	}
+
+	if flagValue == defaultWatchInterval.String() {
+		for i, arg := range args {
+			if d, err := time.ParseDuration(arg); err == nil {
+				interval = d
+				args = slices.Delete(slices.Clone(args), i, i+1)
+				break
+			}
+		}
+	}
+
+	if interval < time.Second {
+		return 0, nil, fmt.Errorf("watch interval must be at least 1s, got %s", interval)
+	}
+	return interval, args, nil
+}
+
+func init() {
+	showCmd.Flags().StringVarP(&watchFlag, "watch", "w", "", "Refresh automatically at the given interval (default 30s)")
+	showCmd.Flags().Lookup("watch").NoOptDefVal = defaultWatchInterval.String()
+	showCmd.Flags().StringVarP(&outputFlag, "output", "o", "", "Print results once instead of opening the dashboard: json, yaml, csv, table")
+	showCmd.Flags().StringVar(&layoutFlag, "layout", "", "Dashboard layout: table or grid (default from config, else table)")
+	showCmd.Flags().BoolVar(&allowErrorsFlag, "allow-errors", false, "Exit with status 0 even if some queries failed (with --output)")
+}
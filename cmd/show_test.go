@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseWatchInterval(t *testing.T) {
+	// --watch without "=" gets the default value, and the interval, if any,
+	// arrives as a positional argument
+	bare := defaultWatchInterval.String()
+
+	tests := []struct {
+		flag     string
+		args     []string
+		interval time.Duration
+		rest     []string
+	}{
+		{bare, nil, 30 * time.Second, nil},
+		{bare, []string{"myapp"}, 30 * time.Second, []string{"myapp"}},
+		{bare, []string{"10s"}, 10 * time.Second, []string{}},
+		{bare, []string{"myapp", "10s"}, 10 * time.Second, []string{"myapp"}},
+		{bare, []string{"10s", "myapp"}, 10 * time.Second, []string{"myapp"}},
+		{"1m", []string{"myapp"}, time.Minute, []string{"myapp"}},
+		{"1m", []string{"10s"}, time.Minute, []string{"10s"}},
+	}
+	for _, tt := range tests {
+		interval, rest, err := parseWatchInterval(tt.flag, tt.args)
+		if err != nil {
+			t.Errorf("parseWatchInterval(%q, %q): %v", tt.flag, tt.args, err)
+			continue
+		}
+		if interval != tt.interval || !reflect.DeepEqual(rest, tt.rest) {
+			t.Errorf("parseWatchInterval(%q, %q) = %s, %q, want %s, %q", tt.flag, tt.args, interval, rest, tt.interval, tt.rest)
+		}
+	}
+
+	for _, flag := range []string{"soon", "500ms"} {
+		if _, _, err := parseWatchInterval(flag, nil); err == nil {
+			t.Errorf("parseWatchInterval(%q): expected an error", flag)
+		}
+	}
+	if _, _, err := parseWatchInterval(bare, []string{"myapp", "100ms"}); err == nil {
+		t.Error("parseWatchInterval with a 100ms argument: expected an error")
+	}
+}
@@ -51,11 +51,15 @@ func BuildPrompt(req QueryRequest) PromptMessages {
 		system.WriteString("Generate queries that return single metrics suitable for dashboard display.\n")
 		system.WriteString("Do not wrap the query in markdown code blocks.")
 	case "mongodb":
-		system.WriteString("Return only the MongoDB query in the format: collection.count({filter}).\n")
-		system.WriteString("Only count() operation is supported. Use JSON format, not JavaScript.\n")
+		system.WriteString("Return only the MongoDB query in one of these formats: collection.count({filter}), collection.aggregate([pipeline]), collection.find({filter}, {\"projection\": {...}, \"sort\": {...}, \"limit\": N}), collection.distinct(\"field\", {filter}) or collection.estimatedDocumentCount().\n")
+		system.WriteString("Use JSON format, not JavaScript. Prefer count() for counts and aggregate() with $group for sums and averages.\n")
 		system.WriteString("For dates, use ISO date strings with $gte/$lt operators.\n")
-		system.WriteString("Examples: users.count({\"status\": \"active\"}) or users.count({\"created_at\": {\"$gte\": \"2024-01-01\"}})\n")
+		system.WriteString("Examples: users.count({\"status\": \"active\"}) or orders.aggregate([{\"$match\": {\"created_at\": {\"$gte\": \"2024-01-01\"}}}, {\"$group\": {\"_id\": null, \"total\": {\"$sum\": \"$amount\"}}}])\n")
 		system.WriteString("Do not wrap the query in markdown code blocks.")
+	case "redis":
+		system.WriteString("Return only a single read-only Redis command, one of: DBSIZE, INFO <section> <field>, SCARD key, LLEN key, ZCARD key, PFCOUNT key, XLEN key or GET key.\n")
+		system.WriteString("Examples: LLEN queue:emails or INFO memory used_memory\n")
+		system.WriteString("Do not wrap the command in markdown code blocks.")
 	}
 
 	var user strings.Builder
@@ -0,0 +1,309 @@
+// Package collector runs the configured queries and gathers their results.
+// It is shared by the dashboard and the non-interactive commands.
+package collector
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/lucasnevespereira/dashmin/internal/db"
+	"golang.org/x/sync/errgroup"
+)
+
+// QueryResult is the outcome of running one query of one app
+type QueryResult struct {
+	AppName     string
+	QueryLabel  string
+	Query       config.Query // definition as configured, before env expansion
+	Result      *db.Result
+	Previous    *db.Result // result for the previous period, if the query has compare set
+	Duration    time.Duration
+	LastUpdated time.Time
+}
+
+// AppNames returns the apps to query in deterministic order. An empty
+// filterApp selects every app.
+func AppNames(cfg *config.Config, filterApp string) []string {
+	var appNames []string
+	for appName := range cfg.Apps {
+		if filterApp != "" && appName != filterApp {
+			continue
+		}
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+	return appNames
+}
+
+// Collect runs the queries of every selected app, one goroutine per app,
+// using connections from conns. Results are ordered by app name, then by
+// query order. Canceling ctx aborts the queries still running.
+func Collect(ctx context.Context, cfg *config.Config, filterApp string, conns *Manager) ([]QueryResult, error) {
+	appNames := AppNames(cfg, filterApp)
+	perApp := make([][]QueryResult, len(appNames))
+
+	g := new(errgroup.Group)
+	for i, appName := range appNames {
+		app := cfg.Apps[appName].WithVars(cfg.Vars)
+		g.Go(func() error {
+			perApp[i] = QueryApp(ctx, appName, app, conns)
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var allResults []QueryResult
+	for _, results := range perApp {
+		allResults = append(allResults, results...)
+	}
+	return allResults, nil
+}
+
+// Selection maps app names to the labels of the queries to run
+type Selection map[string][]string
+
+// SelectAll selects every query of the apps matching filterApp
+func SelectAll(cfg *config.Config, filterApp string) Selection {
+	sel := make(Selection)
+	for _, appName := range AppNames(cfg, filterApp) {
+		sel[appName] = cfg.Apps[appName].SortedLabels()
+	}
+	return sel
+}
+
+// Len returns the number of selected queries
+func (sel Selection) Len() int {
+	n := 0
+	for _, labels := range sel {
+		n += len(labels)
+	}
+	return n
+}
+
+// Stream runs the selected queries like Collect, but sends each result on
+// out as soon as it is ready instead of waiting for every app. out is
+// closed once all queries have finished. Results still unsent when ctx is
+// canceled are dropped.
+func Stream(ctx context.Context, cfg *config.Config, sel Selection, conns *Manager, out chan<- QueryResult) {
+	defer close(out)
+
+	g := new(errgroup.Group)
+	for appName, labels := range sel {
+		app := cfg.Apps[appName].WithVars(cfg.Vars)
+		g.Go(func() error {
+			queryLabels(ctx, appName, app, labels, conns, func(r QueryResult) {
+				select {
+				case out <- r:
+				case <-ctx.Done():
+				}
+			})
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
+
+// QueryApp runs an app's queries sequentially on its managed connection.
+// Connection failures are reported as a single ConnectionLabel result.
+func QueryApp(ctx context.Context, appName string, app config.App, conns *Manager) []QueryResult {
+	return QueryLabels(ctx, appName, app, app.SortedLabels(), conns)
+}
+
+// QueryLabels runs the given queries of an app in order, like QueryApp.
+// Labels the app does not define are skipped.
+func QueryLabels(ctx context.Context, appName string, app config.App, labels []string, conns *Manager) []QueryResult {
+	var results []QueryResult
+	queryLabels(ctx, appName, app, labels, conns, func(r QueryResult) {
+		results = append(results, r)
+	})
+	return results
+}
+
+// queryLabels runs the given queries of an app in order, passing each
+// result to emit as soon as it is ready
+func queryLabels(ctx context.Context, appName string, app config.App, labels []string, conns *Manager, emit func(QueryResult)) {
+	resolved, err := app.Resolve()
+	if err != nil {
+		emit(connectionError(appName, err))
+		return
+	}
+
+	conn, err := conns.Get(appName, app)
+	if err != nil {
+		emit(connectionError(appName, err))
+		return
+	}
+
+	failed := false
+	for _, label := range labels {
+		if _, ok := app.Queries[label]; !ok {
+			continue
+		}
+		q, err := app.ResolveQuery(label)
+		timeout, source := Timeout(app, q)
+		start := time.Now()
+		macros := resolved.Macros(start)
+		var query string
+		if err == nil {
+			query, err = macros.Render(q.Query)
+		}
+		var result *db.Result
+		if err == nil {
+			result, err = db.QueryWithTimeout(ctx, conn, query, timeout, source)
+		}
+		if err != nil {
+			result = &db.Result{Error: err}
+		}
+		failed = failed || result.Error != nil
+		duration := time.Since(start)
+
+		var previous *db.Result
+		if q.Compare != "" && result.Error == nil {
+			previous = QueryPrevious(ctx, conn, macros, q, timeout, source)
+		}
+
+		emit(QueryResult{
+			AppName:     appName,
+			QueryLabel:  label,
+			Query:       app.Queries[label],
+			Result:      result,
+			Previous:    previous,
+			Duration:    duration,
+			LastUpdated: time.Now(),
+		})
+	}
+
+	if failed && ctx.Err() == nil {
+		conns.Check(appName)
+	}
+}
+
+// QueryPrevious runs the comparison of a resolved query: its compare query,
+// or the query itself for the earlier period. Time macros are rendered for
+// that period; queries without them have their date expressions moved back
+// by db.ShiftQuery.
+func QueryPrevious(ctx context.Context, conn db.Connection, macros config.Macros, q config.Query, timeout time.Duration, source string) *db.Result {
+	var query string
+	var err error
+	if days, period := q.CompareDays(); period {
+		query, err = previousPeriod(macros, q.Query, days)
+	} else {
+		query, err = macros.Render(q.Compare)
+	}
+	if err != nil {
+		return &db.Result{Error: err}
+	}
+	result, err := db.QueryWithTimeout(ctx, conn, query, timeout, source)
+	if err != nil {
+		return &db.Result{Error: err}
+	}
+	return result
+}
+
+// previousPeriod renders a query as it would have run the given number of
+// days earlier
+func previousPeriod(macros config.Macros, query string, days int) (string, error) {
+	current, err := macros.Render(query)
+	if err != nil {
+		return "", err
+	}
+	earlier := macros
+	earlier.Now = macros.Now.AddDate(0, 0, -days)
+	shifted, err := earlier.Render(query)
+	if err != nil {
+		return "", err
+	}
+	if shifted != current {
+		return shifted, nil
+	}
+	return db.ShiftQuery(macros.Type, current, days)
+}
+
+// Timeout returns the time limit for a query and the setting it came from:
+// the query's own timeout, then the app's, then db.DefaultTimeout
+func Timeout(app config.App, q config.Query) (time.Duration, string) {
+	switch {
+	case q.Timeout > 0:
+		return q.Timeout, "query timeout"
+	case app.Timeout > 0:
+		return app.Timeout, "app timeout"
+	default:
+		return db.DefaultTimeout, "default timeout"
+	}
+}
+
+// ConnectionLabel labels the result reporting that an app could not connect
+const ConnectionLabel = "Connection"
+
+func connectionError(appName string, err error) QueryResult {
+	return QueryResult{
+		AppName:     appName,
+		QueryLabel:  ConnectionLabel,
+		Result:      &db.Result{Error: err},
+		LastUpdated: time.Now(),
+	}
+}
+
+// HasErrors reports whether any query failed
+func HasErrors(results []QueryResult) bool {
+	for _, r := range results {
+		if r.Result.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ToFloat converts numeric query values, including numeric strings returned
+// by some drivers, to float64. Infinities and NaN, which a float column or a
+// string like "inf" can hold, are not numbers that can be charted, compared
+// or formatted, so they report false.
+func ToFloat(val interface{}) (float64, bool) {
+	f, ok := toFloat(val)
+	if !ok || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+func toFloat(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToFloat(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want float64
+		ok   bool
+	}{
+		{int64(42), 42, true},
+		{int32(-3), -3, true},
+		{uint8(7), 7, true},
+		{float32(1.5), 1.5, true},
+		{2.25, 2.25, true},
+		{" 12.5 ", 12.5, true},
+		{"1e3", 1000, true},
+		{"abc", 0, false},
+		{nil, 0, false},
+		{true, 0, false},
+		{math.Inf(1), 0, false},
+		{math.Inf(-1), 0, false},
+		{math.NaN(), 0, false},
+		{float32(math.Inf(1)), 0, false},
+		{"inf", 0, false},
+		{"-Infinity", 0, false},
+		{"NaN", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ToFloat(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ToFloat(%#v) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
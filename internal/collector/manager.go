@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/lucasnevespereira/dashmin/internal/db"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Connect resolves environment variables in the app's connection string and
+// opens a connection using the app's pool, database and http settings
+func Connect(app config.App) (db.Connection, error) {
+	resolved, err := app.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := db.Options{Database: resolved.Database, Headers: resolved.HTTP.RequestHeaders()}
+	if app.Pool != nil {
+		opts.MaxOpenConns = app.Pool.MaxOpenConns
+		opts.MaxIdleConns = app.Pool.MaxIdleConns
+		opts.ConnMaxLifetime = app.Pool.ConnMaxLifetime
+		opts.ConnMaxIdleTime = app.Pool.ConnMaxIdleTime
+	}
+	return db.ConnectWithOptions(resolved.Type, resolved.Connection, opts)
+}
+
+// Manager keeps one open connection per app for the lifetime of a dashboard
+// or serve session, instead of connecting on every refresh. Failed
+// connections are retried with exponential backoff.
+type Manager struct {
+	mu     sync.Mutex
+	conns  map[string]*managedConn
+	closed bool
+}
+
+type managedConn struct {
+	mu       sync.Mutex // serializes connection attempts for one app
+	conn     db.Connection
+	lastErr  error
+	failures int
+	retryAt  time.Time
+}
+
+func NewManager() *Manager {
+	return &Manager{conns: make(map[string]*managedConn)}
+}
+
+// Get returns the open connection for an app, connecting if needed. While
+// backing off after a failed attempt it returns the last error without
+// trying again.
+func (m *Manager) Get(appName string, app config.App) (db.Connection, error) {
+	mc, err := m.entry(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.conn != nil {
+		return mc.conn, nil
+	}
+	if wait := time.Until(mc.retryAt); wait > 0 {
+		return nil, fmt.Errorf("%w (retrying in %s)", mc.lastErr, wait.Round(time.Second))
+	}
+
+	conn, err := Connect(app)
+	if err != nil {
+		mc.failures++
+		mc.lastErr = err
+		mc.retryAt = time.Now().Add(backoff(mc.failures))
+		return nil, err
+	}
+
+	mc.conn = conn
+	mc.failures = 0
+	mc.lastErr = nil
+	return conn, nil
+}
+
+// Check pings an app's connection after a query failed. A connection that
+// no longer responds is closed so the next Get reconnects.
+func (m *Manager) Check(appName string) {
+	mc, err := m.entry(appName)
+	if err != nil {
+		return
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.conn == nil || mc.conn.Ping() == nil {
+		return
+	}
+	_ = mc.conn.Close()
+	mc.conn = nil
+}
+
+// Close closes every open connection. The manager cannot be used afterwards.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for _, mc := range m.conns {
+		mc.mu.Lock()
+		if mc.conn != nil {
+			errs = append(errs, mc.conn.Close())
+			mc.conn = nil
+		}
+		mc.mu.Unlock()
+	}
+	m.closed = true
+	return errors.Join(errs...)
+}
+
+func (m *Manager) entry(appName string) (*managedConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, errors.New("connection manager is closed")
+	}
+	mc, ok := m.conns[appName]
+	if !ok {
+		mc = &managedConn{}
+		m.conns[appName] = mc
+	}
+	return mc, nil
+}
+
+// backoff returns the delay before the next connection attempt: 1s, 2s, 4s,
+// ... up to one minute
+func backoff(failures int) time.Duration {
+	d := minBackoff
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
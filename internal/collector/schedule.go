@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/lucasnevespereira/dashmin/internal/config"
+)
+
+// Schedule tracks when each query is due. A query runs at its own interval,
+// or at the default interval when it has none; with neither it only runs on
+// demand. Queries that never ran are due right away.
+type Schedule struct {
+	cfg             *config.Config
+	filterApp       string
+	defaultInterval time.Duration
+	next            map[queryKey]time.Time
+}
+
+type queryKey struct {
+	app, label string
+}
+
+// NewSchedule creates a schedule for the queries of the apps matching
+// filterApp
+func NewSchedule(cfg *config.Config, filterApp string, defaultInterval time.Duration) *Schedule {
+	return &Schedule{
+		cfg:             cfg,
+		filterApp:       filterApp,
+		defaultInterval: defaultInterval,
+		next:            make(map[queryKey]time.Time),
+	}
+}
+
+// Interval returns how often a query runs, or 0 if it only runs on demand
+func (s *Schedule) Interval(appName, label string) time.Duration {
+	if q := s.cfg.Apps[appName].Queries[label]; q.Interval > 0 {
+		return q.Interval
+	}
+	return s.defaultInterval
+}
+
+// Scheduled reports whether any query runs on its own
+func (s *Schedule) Scheduled() bool {
+	for appName, labels := range SelectAll(s.cfg, s.filterApp) {
+		for _, label := range labels {
+			if s.Interval(appName, label) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Due returns the scheduled queries whose interval has elapsed at now
+func (s *Schedule) Due(now time.Time) Selection {
+	return s.selectQueries(func(appName, label string) bool {
+		return s.Interval(appName, label) > 0 && !now.Before(s.next[queryKey{appName, label}])
+	})
+}
+
+// Unscheduled returns the queries that only run on demand
+func (s *Schedule) Unscheduled() Selection {
+	return s.selectQueries(func(appName, label string) bool {
+		return s.Interval(appName, label) == 0
+	})
+}
+
+func (s *Schedule) selectQueries(match func(appName, label string) bool) Selection {
+	sel := make(Selection)
+	for appName, labels := range SelectAll(s.cfg, s.filterApp) {
+		for _, label := range labels {
+			if match(appName, label) {
+				sel[appName] = append(sel[appName], label)
+			}
+		}
+	}
+	return sel
+}
+
+// Ran records that the selected queries started at the given time, which
+// makes each due again one interval later
+func (s *Schedule) Ran(sel Selection, at time.Time) {
+	for appName, labels := range sel {
+		for _, label := range labels {
+			s.next[queryKey{appName, label}] = at.Add(s.Interval(appName, label))
+		}
+	}
+}
+
+// Next returns when a scheduled query is due next. ok is false for queries
+// that only run on demand.
+func (s *Schedule) Next(appName, label string) (next time.Time, ok bool) {
+	if s.Interval(appName, label) == 0 {
+		return time.Time{}, false
+	}
+	return s.next[queryKey{appName, label}], true
+}
+
+// Soonest returns when the next scheduled query is due. ok is false when no
+// query is scheduled.
+func (s *Schedule) Soonest() (soonest time.Time, ok bool) {
+	for appName, labels := range SelectAll(s.cfg, s.filterApp) {
+		for _, label := range labels {
+			next, scheduled := s.Next(appName, label)
+			if scheduled && (!ok || next.Before(soonest)) {
+				soonest, ok = next, true
+			}
+		}
+	}
+	return soonest, ok
+}
+
+// Delay postpones every scheduled query by d, e.g. for the time the
+// dashboard was paused
+func (s *Schedule) Delay(d time.Duration) {
+	for key, next := range s.next {
+		s.next[key] = next.Add(d)
+	}
+}
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,7 +16,49 @@ type App struct {
 	Name       string            `yaml:"name"`
 	Type       string            `yaml:"type"` // postgres, mysql, mongodb
 	Connection string            `yaml:"connection"`
-	Queries    map[string]string `yaml:"queries"`
+	Database   string            `yaml:"database,omitempty"` // MongoDB database, overriding the one in the connection string
+	Timeout    time.Duration     `yaml:"timeout,omitempty"`  // default for queries without their own timeout
+	Pool       *PoolConfig       `yaml:"pool,omitempty"`
+	HTTP       *HTTPConfig       `yaml:"http,omitempty"`
+	Vars       map[string]string `yaml:"vars,omitempty"` // query template variables, e.g. {{.Vars.region}}
+	Queries    map[string]Query  `yaml:"queries"`
+}
+
+// PoolConfig tunes the connection pool of SQL apps. Zero values keep the
+// driver defaults.
+type PoolConfig struct {
+	MaxOpenConns    int           `yaml:"max_open_conns,omitempty"`
+	MaxIdleConns    int           `yaml:"max_idle_conns,omitempty"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime,omitempty"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time,omitempty"`
+}
+
+// HTTPConfig sets the headers and authentication of requests made by http
+// apps
+type HTTPConfig struct {
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Username string            `yaml:"username,omitempty"` // basic auth
+	Password string            `yaml:"password,omitempty"`
+	Token    string            `yaml:"token,omitempty"` // sent as "Authorization: Bearer <token>"
+}
+
+// RequestHeaders returns the headers to send, including the Authorization
+// header of basic or token auth
+func (h *HTTPConfig) RequestHeaders() map[string]string {
+	if h == nil {
+		return nil
+	}
+	headers := make(map[string]string, len(h.Headers)+1)
+	for name, value := range h.Headers {
+		headers[name] = value
+	}
+	switch {
+	case h.Token != "":
+		headers["Authorization"] = "Bearer " + h.Token
+	case h.Username != "" || h.Password != "":
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(h.Username+":"+h.Password))
+	}
+	return headers
 }
 
 type AIConfig struct {
@@ -21,8 +67,138 @@ type AIConfig struct {
 }
 
 type Config struct {
-	AI   *AIConfig       `yaml:"ai,omitempty"`
-	Apps map[string]App `yaml:"apps"`
+	AI     *AIConfig         `yaml:"ai,omitempty"`
+	Layout string            `yaml:"layout,omitempty"` // dashboard layout: table (default) or grid
+	Vars   map[string]string `yaml:"vars,omitempty"`   // query template variables shared by every app
+	Apps   map[string]App    `yaml:"apps"`
+}
+
+// Supported values for Config.Layout
+var ValidLayouts = []string{"table", "grid"}
+
+// ValidateLayout checks that a dashboard layout is supported
+func ValidateLayout(layout string) error {
+	if layout == "" {
+		return nil
+	}
+	for _, l := range ValidLayouts {
+		if l == layout {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid layout '%s'. Supported: %s", layout, strings.Join(ValidLayouts, ", "))
+}
+
+// envPattern matches ${VAR} and ${VAR:-default} references
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandEnv replaces ${VAR} and ${VAR:-default} references with values from
+// the environment. Unset variables without a default are an error.
+func ExpandEnv(s string) (string, error) {
+	var missing []string
+	expanded := envPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envPattern.FindStringSubmatch(ref)
+		name, hasDefault, def := m[1], m[2] != "", m[3]
+		value, ok := os.LookupEnv(name)
+		if hasDefault && value == "" {
+			return def
+		}
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// IsEnvReference reports whether s is a single ${VAR} reference
+func IsEnvReference(s string) bool {
+	m := envPattern.FindStringSubmatch(s)
+	return m != nil && m[0] == s && m[2] == ""
+}
+
+// Resolve returns a copy of the app with environment variables expanded in
+// its connection settings and vars. The app itself keeps the unexpanded
+// templates, so saving the config never writes secrets to disk. Queries are
+// expanded one at a time by ResolveQuery, so that a query referencing an
+// unset variable does not stop the app's other queries.
+func (a App) Resolve() (App, error) {
+	connection, err := ExpandEnv(a.Connection)
+	if err != nil {
+		return App{}, fmt.Errorf("app '%s': connection: %w", a.Name, err)
+	}
+
+	database, err := ExpandEnv(a.Database)
+	if err != nil {
+		return App{}, fmt.Errorf("app '%s': database: %w", a.Name, err)
+	}
+
+	resolved := a
+	resolved.Connection = connection
+	resolved.Database = database
+	if a.HTTP != nil {
+		if resolved.HTTP, err = a.HTTP.resolve(); err != nil {
+			return App{}, fmt.Errorf("app '%s': http: %w", a.Name, err)
+		}
+	}
+	resolved.Vars = make(map[string]string, len(a.Vars))
+	for name, value := range a.Vars {
+		expanded, err := ExpandEnv(value)
+		if err != nil {
+			return App{}, fmt.Errorf("app '%s': var '%s': %w", a.Name, name, err)
+		}
+		resolved.Vars[name] = expanded
+	}
+	return resolved, nil
+}
+
+// ResolveQuery returns the app's query with environment variables expanded
+// in its query text and custom compare query
+func (a App) ResolveQuery(label string) (Query, error) {
+	query, ok := a.Queries[label]
+	if !ok {
+		return Query{}, fmt.Errorf("app '%s': query '%s' not found", a.Name, label)
+	}
+	expanded, err := ExpandEnv(query.Query)
+	if err != nil {
+		return Query{}, fmt.Errorf("app '%s': query '%s': %w", a.Name, label, err)
+	}
+	query.Query = expanded
+	if _, period := query.CompareDays(); query.Compare != "" && !period {
+		compare, err := ExpandEnv(query.Compare)
+		if err != nil {
+			return Query{}, fmt.Errorf("app '%s': query '%s': compare: %w", a.Name, label, err)
+		}
+		query.Compare = compare
+	}
+	return query, nil
+}
+
+// resolve returns a copy with environment variables expanded, since headers
+// and credentials are usually secrets
+func (h *HTTPConfig) resolve() (*HTTPConfig, error) {
+	resolved := &HTTPConfig{Headers: make(map[string]string, len(h.Headers))}
+	for name, value := range h.Headers {
+		expanded, err := ExpandEnv(value)
+		if err != nil {
+			return nil, fmt.Errorf("header '%s': %w", name, err)
+		}
+		resolved.Headers[name] = expanded
+	}
+	var err error
+	if resolved.Username, err = ExpandEnv(h.Username); err != nil {
+		return nil, fmt.Errorf("username: %w", err)
+	}
+	if resolved.Password, err = ExpandEnv(h.Password); err != nil {
+		return nil, fmt.Errorf("password: %w", err)
+	}
+	if resolved.Token, err = ExpandEnv(h.Token); err != nil {
+		return nil, fmt.Errorf("token: %w", err)
+	}
+	return resolved, nil
 }
 
 func GetConfigPath() string {
@@ -57,6 +233,13 @@ func Load() (*Config, error) {
 		config.Apps = make(map[string]App)
 	}
 
+	for name, app := range config.Apps {
+		if app.Name == "" {
+			app.Name = name
+			config.Apps[name] = app
+		}
+	}
+
 	return &config, nil
 }
 
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Macros renders the template variables of a query, e.g.
+//
+//	SELECT COUNT(*) FROM users WHERE created_at >= {{.Today}}
+//
+// Dates and times are emitted as literals for the database type, in the
+// local time zone: DATE '2024-01-15' for PostgreSQL and MySQL,
+// '2024-01-15' for SQLite and an ISO string such as "2024-01-15T00:00:00Z"
+// for MongoDB filters. User-defined vars are inserted as written.
+type Macros struct {
+	Type string
+	Now  time.Time
+	Vars map[string]string
+}
+
+// Macros returns the macros for running the app's queries at now
+func (a App) Macros(now time.Time) Macros {
+	return Macros{Type: a.Type, Now: now, Vars: a.Vars}
+}
+
+// WithVars returns a copy of the app whose vars include the global ones it
+// does not define itself
+func (a App) WithVars(global map[string]string) App {
+	if len(global) == 0 {
+		return a
+	}
+	vars := make(map[string]string, len(global)+len(a.Vars))
+	for name, value := range global {
+		vars[name] = value
+	}
+	for name, value := range a.Vars {
+		vars[name] = value
+	}
+	a.Vars = vars
+	return a
+}
+
+// Render executes the query as a text/template. Queries without "{{" are
+// returned unchanged.
+func (m Macros) Render(query string) (string, error) {
+	if !strings.Contains(query, "{{") {
+		return query, nil
+	}
+	tmpl, err := template.New("query").Option("missingkey=error").Parse(query)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, macroData{Vars: m.Vars, macros: m}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// macroData is what query templates see: the time macros as methods and
+// the user-defined vars as .Vars
+type macroData struct {
+	Vars   map[string]string
+	macros Macros
+}
+
+// Today is midnight at the start of the current day
+func (d macroData) Today() string {
+	return d.macros.date(startOfDay(d.macros.Now))
+}
+
+// Yesterday is midnight at the start of the previous day
+func (d macroData) Yesterday() string {
+	return d.macros.date(startOfDay(d.macros.Now).AddDate(0, 0, -1))
+}
+
+// StartOfWeek is midnight on Monday of the current week
+func (d macroData) StartOfWeek() string {
+	today := startOfDay(d.macros.Now)
+	daysSinceMonday := (int(today.Weekday()) + 6) % 7
+	return d.macros.date(today.AddDate(0, 0, -daysSinceMonday))
+}
+
+// StartOfMonth is midnight on the first day of the current month
+func (d macroData) StartOfMonth() string {
+	now := d.macros.Now
+	return d.macros.date(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()))
+}
+
+// Now is the current time
+func (d macroData) Now() string {
+	return d.macros.timestamp(d.macros.Now)
+}
+
+// Ago is the current time minus a duration such as "24h", "30m" or "7d"
+func (d macroData) Ago(age string) (string, error) {
+	duration, err := ParseAge(age)
+	if err != nil {
+		return "", err
+	}
+	return d.macros.timestamp(d.macros.Now.Add(-duration)), nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// date renders midnight of a day as a literal for the database type
+func (m Macros) date(t time.Time) string {
+	switch m.Type {
+	case "postgres", "mysql":
+		return "DATE '" + t.Format("2006-01-02") + "'"
+	case "mongodb":
+		return strconv.Quote(t.Format(time.RFC3339))
+	default:
+		return "'" + t.Format("2006-01-02") + "'"
+	}
+}
+
+// timestamp renders a point in time as a literal for the database type
+func (m Macros) timestamp(t time.Time) string {
+	switch m.Type {
+	case "postgres", "mysql":
+		return "TIMESTAMP '" + t.Format("2006-01-02 15:04:05") + "'"
+	case "mongodb":
+		return strconv.Quote(t.Format(time.RFC3339))
+	default:
+		return "'" + t.Format("2006-01-02 15:04:05") + "'"
+	}
+}
+
+// ParseAge parses a duration that may use d (days) and w (weeks) units
+func ParseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			f, err := strconv.ParseFloat(n, 64)
+			if err != nil || f < 0 {
+				return 0, fmt.Errorf("invalid duration '%s'", s)
+			}
+			return time.Duration(f * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported values for Query.Format, besides printf verbs such as "%.1f"
+var ValidFormats = []string{"number", "percent", "bytes", "duration"}
+
+// Supported values for Query.Display
+var ValidDisplays = []string{"value", "table"}
+
+// Supported values for Query.Size
+var ValidSizes = []string{"small", "medium", "large"}
+
+// ComparePeriods maps the built-in values of Query.Compare to how far back
+// they look, in days. Any other value is a query returning the previous
+// value itself.
+var ComparePeriods = map[string]int{
+	"yesterday": 1,
+	"last_week": 7,
+}
+
+// Query is a labelled metric. In the config file it can be written as a plain
+// string, which is shorthand for a query with no other settings:
+//
+//	queries:
+//	  users: "SELECT COUNT(*) FROM users"
+//	  errors_today:
+//	    query: "SELECT COUNT(*) FROM logs WHERE level = 'error'"
+//	    warn: 10
+//	    critical: 50
+type Query struct {
+	Query       string        `yaml:"query"`
+	Description string        `yaml:"description,omitempty"`
+	Unit        string        `yaml:"unit,omitempty"`
+	Format      string        `yaml:"format,omitempty"` // number, percent, bytes, duration or a printf verb
+	Warn        *Threshold    `yaml:"warn,omitempty"`
+	Critical    *Threshold    `yaml:"critical,omitempty"`
+	Timeout     time.Duration `yaml:"timeout,omitempty"`
+	Interval    time.Duration `yaml:"interval,omitempty"` // how often the dashboard and serve mode re-run the query
+	Order       int           `yaml:"order,omitempty"`
+	Display     string        `yaml:"display,omitempty"` // value (default) or table
+	Group       string        `yaml:"group,omitempty"`   // heading the card is listed under in the grid layout
+	Size        string        `yaml:"size,omitempty"`    // grid card size: small, medium (default) or large
+	Compare     string        `yaml:"compare,omitempty"` // yesterday, last_week or a query for the previous value
+}
+
+// queryFields is Query without its YAML methods, used to avoid recursion
+type queryFields Query
+
+func (q *Query) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*q = Query{Query: node.Value}
+		return nil
+	}
+	return node.Decode((*queryFields)(q))
+}
+
+func (q Query) MarshalYAML() (interface{}, error) {
+	if q == (Query{Query: q.Query}) {
+		return q.Query, nil
+	}
+	return queryFields(q), nil
+}
+
+// ValidateFormat checks that a format is supported
+func ValidateFormat(format string) error {
+	if format == "" || strings.HasPrefix(format, "%") {
+		return nil
+	}
+	for _, f := range ValidFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format '%s'. Supported: %s or a printf verb like %%.1f", format, strings.Join(ValidFormats, ", "))
+}
+
+// ValidateDisplay checks that a display mode is supported
+func ValidateDisplay(display string) error {
+	if display == "" {
+		return nil
+	}
+	for _, d := range ValidDisplays {
+		if d == display {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid display '%s'. Supported: %s", display, strings.Join(ValidDisplays, ", "))
+}
+
+// ValidateSize checks that a grid card size is supported
+func ValidateSize(size string) error {
+	if size == "" {
+		return nil
+	}
+	for _, v := range ValidSizes {
+		if v == size {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid size '%s'. Supported: %s", size, strings.Join(ValidSizes, ", "))
+}
+
+// CompareDays returns how many days back a built-in comparison period looks.
+// ok is false when the query has no comparison or a custom compare query.
+func (q Query) CompareDays() (days int, ok bool) {
+	days, ok = ComparePeriods[q.Compare]
+	return days, ok
+}
+
+// SortedLabels returns the app's query labels in display order: queries with
+// an explicit order first (ascending), then the rest alphabetically
+func (a App) SortedLabels() []string {
+	labels := make([]string, 0, len(a.Queries))
+	for label := range a.Queries {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		oi, oj := a.Queries[labels[i]].Order, a.Queries[labels[j]].Order
+		if oi != oj {
+			if oi == 0 || oj == 0 {
+				return oj == 0
+			}
+			return oi < oj
+		}
+		return labels[i] < labels[j]
+	})
+	return labels
+}
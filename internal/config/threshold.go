@@ -0,0 +1,176 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Direction tells which side of a threshold raises an alert
+type Direction string
+
+const (
+	Above   Direction = "above"   // alert when value >= Value, or > Value if Strict
+	Below   Direction = "below"   // alert when value <= Value, or < Value if Strict
+	Outside Direction = "outside" // alert when value < Min or value > Max
+)
+
+// Threshold is an alert condition on a numeric value. In the config file it
+// is written as a number (alert at or above it) or as a string:
+//
+//	warn: 100               # at or above 100
+//	warn: ">= 100"          # same as above
+//	warn: "> 100"           # above 100
+//	warn: "< 10"            # below 10
+//	warn: "<= 10"           # at or below 10
+//	warn: "outside 10..50"  # below 10 or above 50
+type Threshold struct {
+	Direction Direction
+	Value     float64
+	Strict    bool // > or < rather than >= or <=
+	Min       float64
+	Max       float64
+}
+
+// ParseThreshold parses the textual form of a threshold
+func ParseThreshold(s string) (*Threshold, error) {
+	s = strings.TrimSpace(s)
+	invalid := fmt.Errorf("invalid threshold '%s'. Use a number, \"> N\", \">= N\", \"< N\", \"<= N\" or \"outside MIN..MAX\"", s)
+
+	switch {
+	case strings.HasPrefix(s, "outside"):
+		lo, hi, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(s, "outside")), "..")
+		if !ok {
+			return nil, invalid
+		}
+		minVal, err1 := strconv.ParseFloat(strings.TrimSpace(lo), 64)
+		maxVal, err2 := strconv.ParseFloat(strings.TrimSpace(hi), 64)
+		if err1 != nil || err2 != nil || minVal > maxVal {
+			return nil, invalid
+		}
+		return &Threshold{Direction: Outside, Min: minVal, Max: maxVal}, nil
+	case strings.HasPrefix(s, "<"), strings.HasPrefix(s, "below"):
+		v, strict, err := parseBound(strings.TrimPrefix(s, "below"), "<")
+		if err != nil {
+			return nil, invalid
+		}
+		return &Threshold{Direction: Below, Value: v, Strict: strict}, nil
+	default:
+		v, strict, err := parseBound(strings.TrimPrefix(s, "above"), ">")
+		if err != nil {
+			return nil, invalid
+		}
+		return &Threshold{Direction: Above, Value: v, Strict: strict}, nil
+	}
+}
+
+// parseBound parses "N", "op N" or "op= N". The bound is strict when op is
+// not followed by "=".
+func parseBound(s, op string) (value float64, strict bool, err error) {
+	s = strings.TrimSpace(s)
+	if rest, ok := strings.CutPrefix(s, op); ok {
+		s, strict = rest, true
+		if rest, ok := strings.CutPrefix(s, "="); ok {
+			s, strict = rest, false
+		}
+	}
+	value, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return value, strict, err
+}
+
+// Breached reports whether value triggers the threshold
+func (t *Threshold) Breached(value float64) bool {
+	if t == nil {
+		return false
+	}
+	switch t.Direction {
+	case Below:
+		if t.Strict {
+			return value < t.Value
+		}
+		return value <= t.Value
+	case Outside:
+		return value < t.Min || value > t.Max
+	default:
+		if t.Strict {
+			return value > t.Value
+		}
+		return value >= t.Value
+	}
+}
+
+func (t *Threshold) String() string {
+	switch t.Direction {
+	case Below:
+		return t.operator("<") + " " + formatFloat(t.Value)
+	case Outside:
+		return fmt.Sprintf("outside %s..%s", formatFloat(t.Min), formatFloat(t.Max))
+	default:
+		return t.operator(">") + " " + formatFloat(t.Value)
+	}
+}
+
+func (t *Threshold) operator(op string) string {
+	if t.Strict {
+		return op
+	}
+	return op + "="
+}
+
+func (t *Threshold) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: threshold must be a number or a string", node.Line)
+	}
+	parsed, err := ParseThreshold(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*t = *parsed
+	return nil
+}
+
+func (t Threshold) MarshalYAML() (interface{}, error) {
+	if t.Direction == Above && !t.Strict {
+		return t.Value, nil
+	}
+	return t.String(), nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// AlertLevel is the severity of a value relative to its query's thresholds
+type AlertLevel int
+
+const (
+	AlertNone AlertLevel = iota
+	AlertWarning
+	AlertCritical
+)
+
+func (l AlertLevel) String() string {
+	switch l {
+	case AlertWarning:
+		return "warning"
+	case AlertCritical:
+		return "critical"
+	default:
+		return "ok"
+	}
+}
+
+// AlertLevel evaluates a value against the query's warn and critical
+// thresholds. Critical wins when both are breached.
+func (q Query) AlertLevel(value float64) AlertLevel {
+	switch {
+	case q.Critical.Breached(value):
+		return AlertCritical
+	case q.Warn.Breached(value):
+		return AlertWarning
+	default:
+		return AlertNone
+	}
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// shiftRule moves one kind of date expression back by a number of days
+type shiftRule struct {
+	pattern *regexp.Regexp
+	shift   func(expr string, days int) string
+}
+
+// shiftRules lists the date and time expressions of each SQL dialect that
+// ShiftQuery moves back
+var shiftRules = map[string][]shiftRule{
+	"postgres": {{
+		pattern: regexp.MustCompile(`(?i)\b(?:now\(\)|(?:current_date|current_timestamp|localtimestamp)\b(?:\(\d*\))?)`),
+		shift: func(expr string, days int) string {
+			return fmt.Sprintf("(%s - INTERVAL '%d days')", expr, days)
+		},
+	}},
+	"mysql": {{
+		pattern: regexp.MustCompile(`(?i)\b(?:(?:now|curdate|current_date|current_timestamp|sysdate|utc_date|utc_timestamp|localtime|localtimestamp)\(\d*\)|(?:current_date|current_timestamp|localtime|localtimestamp)\b)`),
+		shift: func(expr string, days int) string {
+			return fmt.Sprintf("(%s - INTERVAL %d DAY)", expr, days)
+		},
+	}},
+	"sqlite": {
+		{
+			// date('now'), datetime('now', 'start of day'), strftime('%s', 'now')...
+			pattern: regexp.MustCompile(`(?i)'now'`),
+			shift: func(expr string, days int) string {
+				return fmt.Sprintf("%s, '-%d days'", expr, days)
+			},
+		},
+		{
+			pattern: regexp.MustCompile(`(?i)\bcurrent_date\b`),
+			shift: func(expr string, days int) string {
+				return fmt.Sprintf("date('now', '-%d days')", days)
+			},
+		},
+		{
+			pattern: regexp.MustCompile(`(?i)\bcurrent_timestamp\b`),
+			shift: func(expr string, days int) string {
+				return fmt.Sprintf("datetime('now', '-%d days')", days)
+			},
+		},
+	},
+}
+
+// ShiftQuery rewrites a query to measure the same window the given number
+// of days earlier, e.g. "signups today" into "signups yesterday". SQL date
+// expressions such as CURRENT_DATE and NOW() are moved back; for MongoDB
+// count queries, the dates in the filter are. A query with nothing to shift
+// is an error, since it would only repeat the current value, and so is a SQL
+// query with only a lower date bound, whose shifted window would run from
+// yesterday through today.
+func ShiftQuery(dbType, query string, days int) (string, error) {
+	if dbType == "mongodb" {
+		return shiftMongoQuery(query, days, time.Now())
+	}
+
+	rules, ok := shiftRules[dbType]
+	if !ok {
+		return "", fmt.Errorf("comparing with a previous period is not supported for %s", dbType)
+	}
+	if lowerBoundOnly(query, rules) {
+		return "", fmt.Errorf("nothing to compare: the query only has a lower date bound (e.g. >= CURRENT_DATE), so moved back it would also count the current period; add an upper bound such as < NOW() or use a custom compare query instead")
+	}
+	shifted := 0
+	for _, rule := range rules {
+		query = rule.pattern.ReplaceAllStringFunc(query, func(expr string) string {
+			shifted++
+			return rule.shift(expr, days)
+		})
+	}
+	if shifted == 0 {
+		return "", fmt.Errorf("nothing to compare: the query has no date expression such as CURRENT_DATE or NOW() to move back; use a custom compare query instead")
+	}
+	return query, nil
+}
+
+// betweenPattern matches BETWEEN, whose range has both bounds
+var betweenPattern = regexp.MustCompile(`(?i)\bbetween\b`)
+
+// lowerBoundOnly reports whether a query compares with a date expression
+// from below, as in created_at >= CURRENT_DATE, and never from above
+func lowerBoundOnly(query string, rules []shiftRule) bool {
+	if betweenPattern.MatchString(query) {
+		return false
+	}
+	lower, upper := false, false
+	for _, rule := range rules {
+		for _, loc := range rule.pattern.FindAllStringIndex(query, -1) {
+			switch comparisonBefore(query[:loc[0]]) {
+			case ">", ">=":
+				lower = true
+			case "<", "<=":
+				upper = true
+			}
+		}
+	}
+	return lower && !upper
+}
+
+// comparisonBefore returns the comparison operator that applies to the
+// expression following prefix, looking through the function calls that
+// wrap it, as in created_at >= date_trunc('day', now())
+func comparisonBefore(prefix string) string {
+	inCall := false
+	for {
+		prefix = strings.TrimRight(prefix, " \t\r\n")
+		switch {
+		case strings.HasSuffix(prefix, "("):
+			prefix = prefix[:len(prefix)-1]
+			inCall = true
+			continue
+		case strings.HasSuffix(prefix, ","):
+			prefix = prefix[:len(prefix)-1]
+			continue
+		case strings.HasSuffix(prefix, "'"):
+			open := strings.LastIndex(prefix[:len(prefix)-1], "'")
+			if open < 0 {
+				return ""
+			}
+			prefix = prefix[:open]
+			continue
+		}
+		if !inCall {
+			break
+		}
+		// The name of the function whose arguments were skipped
+		name := strings.TrimRightFunc(prefix, func(r rune) bool {
+			return r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		})
+		if name == prefix {
+			break
+		}
+		prefix, inCall = name, false
+	}
+	op := strings.TrimRight(prefix, "<>=!")
+	return prefix[len(op):]
+}
+
+// shiftMongoQuery moves the dates of a count filter back. A date range with
+// only a lower bound also gets an upper bound at the same time of day, so
+// "since midnight" compares with "yesterday until this time".
+func shiftMongoQuery(query string, days int, now time.Time) (string, error) {
+	collection, operation, args, err := splitMongoQuery(query)
+	if err != nil || operation != "count" {
+		return "", fmt.Errorf("comparing with a previous period only supports MongoDB count({filter}) queries")
+	}
+	filterStr := shellToExtJSON(args)
+	if strings.TrimSpace(filterStr) == "" {
+		filterStr = "{}"
+	}
+
+	var filter map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(filterStr))
+	dec.UseNumber()
+	if err := dec.Decode(&filter); err != nil {
+		return "", fmt.Errorf("invalid MongoDB filter: %w", err)
+	}
+
+	if !shiftDates(filter, days, now) {
+		return "", fmt.Errorf("nothing to compare: the filter has no date to move back; use a custom compare query instead")
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(filter); err != nil {
+		return "", err
+	}
+	return collection + ".count(" + strings.TrimSpace(b.String()) + ")", nil
+}
+
+// shiftDates moves every date in a filter back, in place, and reports
+// whether it found any. Dates are strings or Extended JSON {"$date": ...}.
+func shiftDates(v interface{}, days int, now time.Time) bool {
+	shifted := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		bounded := false
+		for key, val := range v {
+			s, ok := val.(string)
+			wrapper, isMap := val.(map[string]interface{})
+			if isMap && len(wrapper) == 1 {
+				s, ok = wrapper["$date"].(string)
+			}
+			if ok {
+				if moved, ok := shiftDate(s, days, now); ok {
+					if isMap {
+						wrapper["$date"] = moved
+					} else {
+						v[key] = moved
+					}
+					shifted = true
+					bounded = bounded || key == "$gte" || key == "$gt"
+				}
+				continue
+			}
+			shifted = shiftDates(val, days, now) || shifted
+		}
+		_, upper := v["$lt"]
+		_, upperInclusive := v["$lte"]
+		if bounded && !upper && !upperInclusive {
+			v["$lt"] = now.AddDate(0, 0, -days).UTC().Format(time.RFC3339)
+		}
+	case []interface{}:
+		for _, item := range v {
+			shifted = shiftDates(item, days, now) || shifted
+		}
+	}
+	return shifted
+}
+
+// shiftDate moves a date in one of the forms convertDates understands back.
+// ISO dates keep their layout and relative dates become absolute.
+func shiftDate(s string, days int, now time.Time) (string, bool) {
+	if t, ok, err := relativeDate(s, now); ok {
+		if err != nil {
+			return "", false
+		}
+		return t.AddDate(0, 0, -days).UTC().Format(time.RFC3339), true
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.AddDate(0, 0, -days).Format(layout), true
+		}
+	}
+	return "", false
+}
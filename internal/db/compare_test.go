@@ -0,0 +1,69 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShiftQuery(t *testing.T) {
+	tests := []struct {
+		dbType string
+		query  string
+		want   string
+	}{
+		{"postgres",
+			"SELECT COUNT(*) FROM users WHERE created_at >= CURRENT_DATE AND created_at < NOW()",
+			"SELECT COUNT(*) FROM users WHERE created_at >= (CURRENT_DATE - INTERVAL '1 days') AND created_at < (NOW() - INTERVAL '1 days')"},
+		{"postgres",
+			"SELECT COUNT(*) FROM users WHERE created_at::date = current_date",
+			"SELECT COUNT(*) FROM users WHERE created_at::date = (current_date - INTERVAL '1 days')"},
+		{"postgres",
+			"SELECT COUNT(*) FROM users WHERE created_at BETWEEN CURRENT_DATE AND NOW()",
+			"SELECT COUNT(*) FROM users WHERE created_at BETWEEN (CURRENT_DATE - INTERVAL '1 days') AND (NOW() - INTERVAL '1 days')"},
+		{"postgres",
+			"SELECT COUNT(*) FROM users WHERE created_at >= date_trunc('day', now()) AND created_at <= now()",
+			"SELECT COUNT(*) FROM users WHERE created_at >= date_trunc('day', (now() - INTERVAL '1 days')) AND created_at <= (now() - INTERVAL '1 days')"},
+		{"mysql",
+			"SELECT COUNT(*) FROM users WHERE created_at >= CURDATE() AND created_at < NOW()",
+			"SELECT COUNT(*) FROM users WHERE created_at >= (CURDATE() - INTERVAL 1 DAY) AND created_at < (NOW() - INTERVAL 1 DAY)"},
+		{"sqlite",
+			"SELECT COUNT(*) FROM users WHERE created_at >= date('now') AND created_at < datetime('now')",
+			"SELECT COUNT(*) FROM users WHERE created_at >= date('now', '-1 days') AND created_at < datetime('now', '-1 days')"},
+		{"sqlite",
+			"SELECT COUNT(*) FROM users WHERE date(created_at) = CURRENT_DATE",
+			"SELECT COUNT(*) FROM users WHERE date(created_at) = date('now', '-1 days')"},
+	}
+	for _, tt := range tests {
+		got, err := ShiftQuery(tt.dbType, tt.query, 1)
+		if err != nil {
+			t.Errorf("ShiftQuery(%s, %s): %v", tt.dbType, tt.query, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ShiftQuery(%s, %s) =\n  %s\nwant\n  %s", tt.dbType, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestShiftQueryErrors(t *testing.T) {
+	tests := []struct {
+		dbType string
+		query  string
+		want   string
+	}{
+		{"postgres", "SELECT COUNT(*) FROM users", "no date expression"},
+		{"redis", "DBSIZE", "not supported for redis"},
+		{"postgres", "SELECT COUNT(*) FROM users WHERE created_at >= CURRENT_DATE", "only has a lower date bound"},
+		{"postgres", "SELECT COUNT(*) FROM users WHERE created_at > NOW() - INTERVAL '24 hours'", "only has a lower date bound"},
+		{"postgres", "SELECT COUNT(*) FROM users WHERE amount < 100 AND created_at >= date_trunc('week', now())", "only has a lower date bound"},
+		{"mysql", "SELECT COUNT(*) FROM users WHERE created_at >= CURDATE()", "only has a lower date bound"},
+		{"sqlite", "SELECT COUNT(*) FROM users WHERE created_at >= datetime('now', 'start of day')", "only has a lower date bound"},
+		{"sqlite", "SELECT COUNT(*) FROM users WHERE created_at > strftime('%s', 'now')", "only has a lower date bound"},
+	}
+	for _, tt := range tests {
+		_, err := ShiftQuery(tt.dbType, tt.query, 1)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ShiftQuery(%s, %s): got error %v, want it to contain %q", tt.dbType, tt.query, err, tt.want)
+		}
+	}
+}
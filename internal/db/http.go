@@ -0,0 +1,259 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxResponseSize limits how much of a response body is read
+const maxResponseSize = 10 << 20
+
+type HTTPConnection struct {
+	client  *http.Client
+	baseURL *url.URL
+	headers map[string]string
+}
+
+func (c *HTTPConnection) Query(query string) (*Result, error) {
+	return QueryWithTimeout(context.Background(), c, query, DefaultTimeout, "default timeout")
+}
+
+// QueryContext requests a path of the base URL and extracts values from the
+// JSON response, e.g. "GET /health | .queue.depth". Without an expression
+// the whole response is the result.
+func (c *HTTPConnection) QueryContext(ctx context.Context, query string) (*Result, error) {
+	path, expr, err := parseHTTPQuery(query)
+	if err != nil {
+		return &Result{Error: err}, nil
+	}
+	steps, err := parseJSONExpression(expr)
+	if err != nil {
+		return &Result{Error: err}, nil
+	}
+
+	body, err := c.get(ctx, path)
+	if err != nil {
+		return &Result{Error: contextError(ctx, err)}, nil
+	}
+
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		if expr != "" {
+			return &Result{Error: fmt.Errorf("GET %s: response is not JSON: %w", path, err)}, nil
+		}
+		// Plain text endpoints such as /health returning "OK"
+		return &Result{
+			Columns: []string{"body"},
+			Rows:    [][]interface{}{{strings.TrimSpace(string(body))}},
+		}, nil
+	}
+
+	values, iterated, err := evaluateJSON(steps, jsonNumbers(doc))
+	if err != nil {
+		return &Result{Error: fmt.Errorf("%s: %w", expr, err)}, nil
+	}
+	return jsonResult(values, iterated, resultColumn(steps)), nil
+}
+
+// parseHTTPQuery splits a query into the path and the expression after the
+// first "|". Only GET requests are made.
+func parseHTTPQuery(query string) (path, expr string, err error) {
+	request, expr, _ := strings.Cut(query, "|")
+	fields := strings.Fields(request)
+	if len(fields) == 2 {
+		if !strings.EqualFold(fields[0], "GET") {
+			return "", "", fmt.Errorf("unsupported method '%s': only GET requests are made", fields[0])
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 1 || !strings.HasPrefix(fields[0], "/") {
+		return "", "", fmt.Errorf("invalid HTTP query. Use: GET /path | .field")
+	}
+	return fields[0], strings.TrimSpace(expr), nil
+}
+
+// get requests a path relative to the base URL and returns the body of a
+// successful response
+func (c *HTTPConnection) get(ctx context.Context, path string) ([]byte, error) {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path '%s': %w", path, err)
+	}
+	u := *c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + ref.Path
+	u.RawQuery = ref.RawQuery
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet := strings.TrimSpace(string(body))
+		if len(snippet) > 200 {
+			snippet = snippet[:200] + "..."
+		}
+		return nil, fmt.Errorf("GET %s: %s: %s", path, resp.Status, snippet)
+	}
+	return body, nil
+}
+
+// Ping checks that the server answers. Any response counts, since the base
+// URL itself need not be an endpoint.
+func (c *HTTPConnection) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *HTTPConnection) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// ConnectHTTP connects to a JSON HTTP API. Queries are paths relative to
+// baseURL, e.g. https://status.internal, and headers are sent with every
+// request.
+func ConnectHTTP(baseURL string, headers map[string]string) (Connection, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid http connection string '%s': expected a URL like https://host:port", baseURL)
+	}
+
+	conn := &HTTPConnection{client: &http.Client{}, baseURL: u, headers: headers}
+	if err := conn.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", u.Host, err)
+	}
+	return conn, nil
+}
+
+// jsonResult turns the values of an expression into a table. A single
+// array, or the values of an iteration such as .items[], become rows;
+// objects are flattened into dotted columns like Mongo documents.
+func jsonResult(values []interface{}, iterated bool, column string) *Result {
+	if !iterated && len(values) == 1 {
+		if items, ok := values[0].([]interface{}); ok {
+			values = items
+		} else if _, ok := values[0].(map[string]interface{}); !ok {
+			return &Result{Columns: []string{column}, Rows: [][]interface{}{{jsonValue(values[0])}}}
+		}
+	}
+
+	allObjects := len(values) > 0
+	for _, v := range values {
+		if _, ok := v.(map[string]interface{}); !ok {
+			allObjects = false
+			break
+		}
+	}
+	if !allObjects {
+		rows := make([][]interface{}, len(values))
+		for i, v := range values {
+			rows[i] = []interface{}{jsonValue(v)}
+		}
+		return &Result{Columns: []string{column}, Rows: rows}
+	}
+
+	var columns []string
+	index := make(map[string]int)
+	flat := make([]map[string]interface{}, len(values))
+	for i, v := range values {
+		flat[i] = make(map[string]interface{})
+		flattenJSON("", v.(map[string]interface{}), func(key string, value interface{}) {
+			if _, ok := index[key]; !ok {
+				index[key] = len(columns)
+				columns = append(columns, key)
+			}
+			flat[i][key] = value
+		})
+	}
+	rows := make([][]interface{}, len(flat))
+	for i, fields := range flat {
+		row := make([]interface{}, len(columns))
+		for key, value := range fields {
+			row[index[key]] = value
+		}
+		rows[i] = row
+	}
+	return &Result{Columns: columns, Rows: rows}
+}
+
+func flattenJSON(prefix string, obj map[string]interface{}, add func(key string, value interface{})) {
+	for _, key := range sortedKeys(obj) {
+		if nested, ok := obj[key].(map[string]interface{}); ok && len(nested) > 0 {
+			flattenJSON(prefix+key+".", nested, add)
+			continue
+		}
+		add(prefix+key, jsonValue(obj[key]))
+	}
+}
+
+// jsonValue renders arrays and objects as JSON text; other values are kept
+func jsonValue(v interface{}) interface{} {
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	default:
+		return v
+	}
+}
+
+// jsonNumbers converts the json.Numbers of a decoded document to int64 or
+// float64, in place
+func jsonNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonNumbers(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,215 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// statusServer serves a small JSON status API under /api and records the
+// requests it receives
+type statusServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newStatusServer(t *testing.T) *statusServer {
+	t.Helper()
+	s := &statusServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{
+			"status": "ok",
+			"queue": {"depth": 42, "lag": 1.5},
+			"services": [
+				{"name": "api", "up": true, "meta": {"version": "1.2"}},
+				{"name": "db", "up": false}
+			]
+		}`)
+	})
+	mux.HandleFunc("/api/workers", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"state": %q, "items": [{"id": 1}, {"id": 2}]}`, r.URL.Query().Get("state"))
+	})
+	mux.HandleFunc("/api/ping", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "OK\n")
+	})
+	mux.HandleFunc("/api/broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	})
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *statusServer) lastRequest() *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+func TestHTTPQueries(t *testing.T) {
+	srv := newStatusServer(t)
+	conn, err := ConnectHTTP(srv.URL+"/api/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	tests := []struct {
+		query string
+		want  *Result
+	}{
+		{"GET /health | .queue.depth", &Result{Columns: []string{"depth"}, Rows: [][]interface{}{{int64(42)}}}},
+		{"get /health | .queue.lag", &Result{Columns: []string{"lag"}, Rows: [][]interface{}{{1.5}}}},
+		{"/health | .status", &Result{Columns: []string{"status"}, Rows: [][]interface{}{{"ok"}}}},
+		{"GET /health | .services | length", &Result{Columns: []string{"length"}, Rows: [][]interface{}{{int64(2)}}}},
+		{"GET /health | .services[].name", &Result{Columns: []string{"name"}, Rows: [][]interface{}{{"api"}, {"db"}}}},
+		{"GET /health | .services", &Result{
+			Columns: []string{"meta.version", "name", "up"},
+			Rows:    [][]interface{}{{"1.2", "api", true}, {nil, "db", false}},
+		}},
+		{"GET /health | .queue", &Result{Columns: []string{"depth", "lag"}, Rows: [][]interface{}{{int64(42), 1.5}}}},
+		{"GET /workers?state=down | .state", &Result{Columns: []string{"state"}, Rows: [][]interface{}{{"down"}}}},
+		{"GET /workers?state=down | .items[-1].id", &Result{Columns: []string{"id"}, Rows: [][]interface{}{{int64(2)}}}},
+		{"GET /ping", &Result{Columns: []string{"body"}, Rows: [][]interface{}{{"OK"}}}},
+	}
+	for _, tt := range tests {
+		result, err := conn.Query(tt.query)
+		if err == nil {
+			err = result.Error
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.want) {
+			t.Errorf("%s = %v %v, want %v %v", tt.query, result.Columns, result.Rows, tt.want.Columns, tt.want.Rows)
+		}
+	}
+}
+
+func TestHTTPRequestURL(t *testing.T) {
+	srv := newStatusServer(t)
+
+	tests := []struct {
+		base     string
+		query    string
+		path     string
+		rawQuery string
+	}{
+		{srv.URL, "GET /api/health", "/api/health", ""},
+		{srv.URL + "/api", "GET /health", "/api/health", ""},
+		{srv.URL + "/api/", "GET /health", "/api/health", ""},
+		{srv.URL + "/api?ignored=1", "GET /workers?state=down&limit=5", "/api/workers", "state=down&limit=5"},
+	}
+	for _, tt := range tests {
+		conn, err := ConnectHTTP(tt.base, nil)
+		if err != nil {
+			t.Fatalf("ConnectHTTP(%s): %v", tt.base, err)
+		}
+		if _, err := conn.Query(tt.query); err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		_ = conn.Close()
+
+		r := srv.lastRequest()
+		if r.Method != http.MethodGet || r.URL.Path != tt.path || r.URL.RawQuery != tt.rawQuery {
+			t.Errorf("%s on %s requested %s %s?%s, want GET %s?%s", tt.query, tt.base, r.Method, r.URL.Path, r.URL.RawQuery, tt.path, tt.rawQuery)
+		}
+	}
+}
+
+func TestHTTPHeaders(t *testing.T) {
+	srv := newStatusServer(t)
+	headers := map[string]string{
+		"Authorization": "Bearer secret-token",
+		"X-Team":        "payments",
+	}
+	conn, err := ConnectHTTP(srv.URL+"/api", headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if r := srv.lastRequest(); r.Header.Get("Authorization") != "Bearer secret-token" {
+		t.Errorf("ping sent Authorization %q", r.Header.Get("Authorization"))
+	}
+	if _, err := conn.Query("GET /health"); err != nil {
+		t.Fatal(err)
+	}
+	r := srv.lastRequest()
+	for name, value := range headers {
+		if got := r.Header.Get(name); got != value {
+			t.Errorf("%s header = %q, want %q", name, got, value)
+		}
+	}
+	if got := r.Header.Get("Accept"); got != "application/json" {
+		t.Errorf("Accept header = %q, want application/json", got)
+	}
+}
+
+func TestHTTPQueryErrors(t *testing.T) {
+	srv := newStatusServer(t)
+	conn, err := ConnectHTTP(srv.URL+"/api", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"GET /broken", "503 Service Unavailable: down for maintenance"},
+		{"GET /missing | .a", "404 Not Found"},
+		{"POST /health", "unsupported method 'POST'"},
+		{"DELETE /health | .status", "unsupported method 'DELETE'"},
+		{"GET health", "invalid HTTP query"},
+		{"GET /health extra", "invalid HTTP query"},
+		{"", "invalid HTTP query"},
+		{"GET /ping | .status", "response is not JSON"},
+		{"GET /health | status", "invalid expression"},
+		{"GET /health | .status.code", "cannot get key 'code' of string"},
+	}
+	for _, tt := range tests {
+		result, err := conn.Query(tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if result.Error == nil || !strings.Contains(result.Error.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to contain %q", tt.query, result.Error, tt.want)
+		}
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, r := range srv.requests {
+		if r.Method != http.MethodGet {
+			t.Errorf("sent a %s request", r.Method)
+		}
+	}
+}
+
+func TestConnectHTTPErrors(t *testing.T) {
+	srv := newStatusServer(t)
+	unreachable := srv.URL
+	srv.Close()
+
+	for _, base := range []string{"status.internal", "ftp://status.internal", "https://", "://bad", unreachable} {
+		if conn, err := ConnectHTTP(base, nil); err == nil {
+			_ = conn.Close()
+			t.Errorf("ConnectHTTP(%s): expected an error", base)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonStep is one step of a jq-style expression: a key (.queue), an index
+// ([0], negative from the end), an iteration ([]) or the length function
+type jsonStep struct {
+	key     string
+	index   int
+	kind    jsonStepKind
+	display string
+}
+
+type jsonStepKind int
+
+const (
+	stepKey jsonStepKind = iota
+	stepIndex
+	stepIterate
+	stepLength
+)
+
+// parseJSONExpression parses the subset of jq used to pick values out of a
+// response: paths such as .queue.depth, .items[0].name or .["a key"],
+// iteration with .items[], and "| length". An empty expression selects the
+// whole document.
+func parseJSONExpression(expr string) ([]jsonStep, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	var steps []jsonStep
+	for _, stage := range strings.Split(expr, "|") {
+		stage = strings.TrimSpace(stage)
+		switch {
+		case stage == "length":
+			steps = append(steps, jsonStep{kind: stepLength, display: "length"})
+		case strings.HasPrefix(stage, "."):
+			path, err := parseJSONPath(stage)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expression '%s': %w", expr, err)
+			}
+			steps = append(steps, path...)
+		default:
+			return nil, fmt.Errorf("invalid expression '%s': expected a path like .a.b or length, got '%s'", expr, stage)
+		}
+	}
+	return steps, nil
+}
+
+func parseJSONPath(path string) ([]jsonStep, error) {
+	var steps []jsonStep
+	i := 0
+	for i < len(path) {
+		switch {
+		case path[i] == '.':
+			i++
+			if i < len(path) && isKeyStart(path[i]) {
+				start := i
+				for i < len(path) && isKeyByte(path[i]) {
+					i++
+				}
+				steps = append(steps, jsonStep{kind: stepKey, key: path[start:i], display: path[start:i]})
+			} else if i < len(path) && path[i] == '"' {
+				key, n, err := readJSONString(path[i:])
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, jsonStep{kind: stepKey, key: key, display: key})
+				i += n
+			} else if i < len(path) && path[i] != '[' {
+				return nil, fmt.Errorf("unexpected '%c' at position %d", path[i], i+1)
+			}
+		case path[i] == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' after position %d", i+1)
+			}
+			inner := strings.TrimSpace(path[i+1 : i+end])
+			switch {
+			case inner == "":
+				steps = append(steps, jsonStep{kind: stepIterate, display: "[]"})
+			case strings.HasPrefix(inner, `"`):
+				key, n, err := readJSONString(inner)
+				if err != nil || n != len(inner) {
+					return nil, fmt.Errorf("invalid key %s", inner)
+				}
+				steps = append(steps, jsonStep{kind: stepKey, key: key, display: key})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index [%s]", inner)
+				}
+				steps = append(steps, jsonStep{kind: stepIndex, index: n, display: "[" + inner + "]"})
+			}
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("unexpected '%c' at position %d", path[i], i+1)
+		}
+	}
+	return steps, nil
+}
+
+// readJSONString reads a quoted string at the start of s, returning it and
+// the number of bytes it took
+func readJSONString(s string) (string, int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			key, err := strconv.Unquote(s[:i+1])
+			return key, i + 1, err
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string %s", s)
+}
+
+func isKeyStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isKeyByte(c byte) bool {
+	return isKeyStart(c) || c >= '0' && c <= '9'
+}
+
+// evaluateJSON applies the steps to a document. iterated reports whether
+// an iteration produced the values, so even one value is a row of a list.
+func evaluateJSON(steps []jsonStep, doc interface{}) (values []interface{}, iterated bool, err error) {
+	values = []interface{}{doc}
+	for _, step := range steps {
+		var next []interface{}
+		for _, v := range values {
+			switch step.kind {
+			case stepKey:
+				obj, ok := v.(map[string]interface{})
+				if !ok && v != nil {
+					return nil, false, fmt.Errorf("cannot get key '%s' of %s", step.key, jsonType(v))
+				}
+				next = append(next, obj[step.key])
+			case stepIndex:
+				items, ok := v.([]interface{})
+				if !ok && v != nil {
+					return nil, false, fmt.Errorf("cannot index %s with %s", jsonType(v), step.display)
+				}
+				i := step.index
+				if i < 0 {
+					i += len(items)
+				}
+				if i < 0 || i >= len(items) {
+					next = append(next, nil)
+				} else {
+					next = append(next, items[i])
+				}
+			case stepIterate:
+				switch v := v.(type) {
+				case []interface{}:
+					next = append(next, v...)
+				case map[string]interface{}:
+					for _, key := range sortedKeys(v) {
+						next = append(next, v[key])
+					}
+				default:
+					return nil, false, fmt.Errorf("cannot iterate over %s", jsonType(v))
+				}
+				iterated = true
+			case stepLength:
+				n, err := jsonLength(v)
+				if err != nil {
+					return nil, false, err
+				}
+				next = append(next, n)
+			}
+		}
+		values = next
+	}
+	return values, iterated, nil
+}
+
+func jsonLength(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case []interface{}:
+		return int64(len(v)), nil
+	case map[string]interface{}:
+		return int64(len(v)), nil
+	case string:
+		return int64(len([]rune(v))), nil
+	default:
+		return 0, fmt.Errorf("%s has no length", jsonType(v))
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int64, float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// resultColumn names the column of scalar results after the last key of
+// the expression, e.g. depth for .queue.depth
+func resultColumn(steps []jsonStep) string {
+	for i := len(steps) - 1; i >= 0; i-- {
+		switch steps[i].kind {
+		case stepKey, stepLength:
+			return steps[i].display
+		}
+	}
+	return "value"
+}
+
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDocument = `{
+	"a": {"b": 1, "c": [10, 20, 30]},
+	"a key": "spaced",
+	"name": "héllo",
+	"items": [{"name": "x", "n": 1}, {"name": "y", "n": 2.5}, {"name": "z"}],
+	"obj": {"z": 3, "y": 2},
+	"empty": null
+}`
+
+func testJSON(t *testing.T) interface{} {
+	t.Helper()
+	var doc interface{}
+	dec := json.NewDecoder(strings.NewReader(testDocument))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	return jsonNumbers(doc)
+}
+
+func TestEvaluateJSON(t *testing.T) {
+	doc := testJSON(t)
+
+	tests := []struct {
+		expr     string
+		want     []interface{}
+		iterated bool
+	}{
+		{".a.b", []interface{}{int64(1)}, false},
+		{" .a.b ", []interface{}{int64(1)}, false},
+		{".a.c[0]", []interface{}{int64(10)}, false},
+		{".a.c[-1]", []interface{}{int64(30)}, false},
+		{".a.c[3]", []interface{}{nil}, false},
+		{".a.c[-4]", []interface{}{nil}, false},
+		{`.["a key"]`, []interface{}{"spaced"}, false},
+		{`."a key"`, []interface{}{"spaced"}, false},
+		{".missing", []interface{}{nil}, false},
+		{".missing.deeper[0]", []interface{}{nil}, false},
+		{".a.c[]", []interface{}{int64(10), int64(20), int64(30)}, true},
+		{".items[].name", []interface{}{"x", "y", "z"}, true},
+		{".items[].n", []interface{}{int64(1), 2.5, nil}, true},
+		{".obj[]", []interface{}{int64(2), int64(3)}, true},
+		{".items | length", []interface{}{int64(3)}, false},
+		{".obj | length", []interface{}{int64(2)}, false},
+		{".name | length", []interface{}{int64(5)}, false},
+		{".empty | length", []interface{}{int64(0)}, false},
+		{".items[] | .name | length", []interface{}{int64(1), int64(1), int64(1)}, true},
+	}
+	for _, tt := range tests {
+		steps, err := parseJSONExpression(strings.TrimSpace(tt.expr))
+		if err != nil {
+			t.Errorf("parseJSONExpression(%s): %v", tt.expr, err)
+			continue
+		}
+		got, iterated, err := evaluateJSON(steps, doc)
+		if err != nil {
+			t.Errorf("evaluateJSON(%s): %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || iterated != tt.iterated {
+			t.Errorf("evaluateJSON(%s) = %#v (iterated %v), want %#v (iterated %v)", tt.expr, got, iterated, tt.want, tt.iterated)
+		}
+	}
+
+	whole, _, err := evaluateJSON(nil, doc)
+	if err != nil || !reflect.DeepEqual(whole, []interface{}{doc}) {
+		t.Errorf("empty expression: got %v, %v, want the whole document", whole, err)
+	}
+}
+
+func TestEvaluateJSONErrors(t *testing.T) {
+	doc := testJSON(t)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{".a.b.c", "cannot get key 'c' of number"},
+		{".name.first", "cannot get key 'first' of string"},
+		{".items.name", "cannot get key 'name' of array"},
+		{".a[0]", "cannot index object with [0]"},
+		{".name[-1]", "cannot index string with [-1]"},
+		{".a.b[]", "cannot iterate over number"},
+		{".empty[]", "cannot iterate over null"},
+		{".a.b | length", "number has no length"},
+		{".items[0].name[0]", "cannot index string with [0]"},
+	}
+	for _, tt := range tests {
+		steps, err := parseJSONExpression(tt.expr)
+		if err != nil {
+			t.Errorf("parseJSONExpression(%s): %v", tt.expr, err)
+			continue
+		}
+		_, _, err = evaluateJSON(steps, doc)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("evaluateJSON(%s): got error %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestParseJSONExpressionErrors(t *testing.T) {
+	for _, expr := range []string{
+		"a.b",
+		".a..b",
+		".a[",
+		".a[x]",
+		".a[1.5]",
+		".a[1:]",
+		`.["a`,
+		`.["a" "b"]`,
+		`."a`,
+		".a-b",
+		".a | keys",
+		".a |",
+		"length(.a)",
+	} {
+		if _, err := parseJSONExpression(expr); err == nil {
+			t.Errorf("parseJSONExpression(%s): expected an error", expr)
+		}
+	}
+}
+
+func TestResultColumn(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "value"},
+		{".queue.depth", "depth"},
+		{`.["a key"]`, "a key"},
+		{".items[0]", "items"},
+		{".items[]", "items"},
+		{".items | length", "length"},
+		{".[0]", "value"},
+	}
+	for _, tt := range tests {
+		steps, err := parseJSONExpression(tt.expr)
+		if err != nil {
+			t.Fatalf("parseJSONExpression(%s): %v", tt.expr, err)
+		}
+		if got := resultColumn(steps); got != tt.want {
+			t.Errorf("resultColumn(%s) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
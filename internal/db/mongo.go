@@ -0,0 +1,473 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoUsage lists the supported MongoDB query forms
+const mongoUsage = `collection.count({filter}), collection.find({filter}, {"projection": {...}, "sort": {...}, "limit": N}), collection.aggregate([pipeline]), collection.distinct("field", {filter}) or collection.estimatedDocumentCount()`
+
+// mongoQuery is a parsed MongoDB query such as
+//
+//	orders.aggregate([{"$group": {"_id": null, "total": {"$sum": "$amount"}}}])
+type mongoQuery struct {
+	collection string
+	operation  string
+	args       bson.A
+}
+
+// parseMongoQuery splits a query into collection, operation and JSON
+// arguments. Collection names may contain dots, e.g. system.profile.
+func parseMongoQuery(query string) (*mongoQuery, error) {
+	collection, operation, rawArgs, err := splitMongoQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	args, err := parseMongoArgs(rawArgs)
+	if err != nil {
+		return nil, err
+	}
+	return &mongoQuery{
+		collection: collection,
+		operation:  operation,
+		args:       args,
+	}, nil
+}
+
+// splitMongoQuery splits a query at the last "." before the arguments, and
+// returns the arguments as written
+func splitMongoQuery(query string) (collection, operation, args string, err error) {
+	query = strings.TrimSpace(query)
+	open := strings.Index(query, "(")
+	if open < 0 || !strings.HasSuffix(query, ")") {
+		return "", "", "", fmt.Errorf("invalid MongoDB query format. Use: %s", mongoUsage)
+	}
+	target := strings.TrimSpace(query[:open])
+	dot := strings.LastIndex(target, ".")
+	if dot <= 0 || dot == len(target)-1 {
+		return "", "", "", fmt.Errorf("invalid MongoDB query format. Use: %s", mongoUsage)
+	}
+	return target[:dot], target[dot+1:], query[open+1 : len(query)-1], nil
+}
+
+// parseMongoArgs decodes comma-separated Extended JSON arguments, e.g.
+// {"_id": {"$oid": "..."}} or {"$date": "2024-01-01T00:00:00Z"}. Documents
+// keep their key order, which matters for sort and pipeline stages.
+func parseMongoArgs(s string) (bson.A, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var wrapper struct {
+		Args bson.A `bson:"args"`
+	}
+	if err := bson.UnmarshalExtJSON([]byte(`{"args": [`+shellToExtJSON(s)+`]}`), false, &wrapper); err != nil {
+		return nil, fmt.Errorf("invalid MongoDB query arguments: %w", err)
+	}
+	return wrapper.Args, nil
+}
+
+// document returns argument i as a document, or an empty document when it
+// was not given
+func (q *mongoQuery) document(i int, name string) (bson.D, error) {
+	if i >= len(q.args) {
+		return bson.D{}, nil
+	}
+	doc, ok := q.args[i].(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s must be a JSON object", q.operation, name)
+	}
+	return doc, nil
+}
+
+// maxArgs reports an error when the operation got more than n arguments
+func (q *mongoQuery) maxArgs(n int) error {
+	if len(q.args) > n {
+		return fmt.Errorf("%s: expected at most %d argument(s), got %d", q.operation, n, len(q.args))
+	}
+	return nil
+}
+
+func runMongoQuery(ctx context.Context, db *mongo.Database, q *mongoQuery) (*Result, error) {
+	// The arguments are converted in place
+	if _, err := convertDates(q.args, time.Now()); err != nil {
+		return nil, err
+	}
+
+	coll := db.Collection(q.collection)
+	switch q.operation {
+	case "count":
+		return mongoCount(ctx, coll, q)
+	case "estimatedDocumentCount":
+		return mongoEstimatedCount(ctx, coll, q)
+	case "find":
+		return mongoFind(ctx, coll, q)
+	case "aggregate":
+		return mongoAggregate(ctx, coll, q)
+	case "distinct":
+		return mongoDistinct(ctx, coll, q)
+	default:
+		return nil, fmt.Errorf("unsupported MongoDB operation '%s'. Use: %s", q.operation, mongoUsage)
+	}
+}
+
+func mongoCount(ctx context.Context, coll *mongo.Collection, q *mongoQuery) (*Result, error) {
+	if err := q.maxArgs(1); err != nil {
+		return nil, err
+	}
+	filter, err := q.document(0, "filter")
+	if err != nil {
+		return nil, err
+	}
+	count, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return countResult(count), nil
+}
+
+func mongoEstimatedCount(ctx context.Context, coll *mongo.Collection, q *mongoQuery) (*Result, error) {
+	if err := q.maxArgs(0); err != nil {
+		return nil, err
+	}
+	count, err := coll.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return countResult(count), nil
+}
+
+func countResult(count int64) *Result {
+	return &Result{
+		Columns: []string{"count"},
+		Rows:    [][]interface{}{{count}},
+	}
+}
+
+func mongoFind(ctx context.Context, coll *mongo.Collection, q *mongoQuery) (*Result, error) {
+	if err := q.maxArgs(2); err != nil {
+		return nil, err
+	}
+	filter, err := q.document(0, "filter")
+	if err != nil {
+		return nil, err
+	}
+	opts, err := q.document(1, "options")
+	if err != nil {
+		return nil, err
+	}
+	findOpts, err := findOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := coll.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []bson.D
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return documentsResult(docs), nil
+}
+
+// findOptions reads the options document of find
+func findOptions(doc bson.D) (*options.FindOptions, error) {
+	opts := options.Find()
+	for _, e := range doc {
+		switch e.Key {
+		case "projection", "sort":
+			value, ok := e.Value.(bson.D)
+			if !ok {
+				return nil, fmt.Errorf("find: %s must be a JSON object", e.Key)
+			}
+			if e.Key == "projection" {
+				opts.SetProjection(value)
+			} else {
+				opts.SetSort(value)
+			}
+		case "limit", "skip":
+			n, ok := wholeNumber(e.Value)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("find: %s must be a positive whole number", e.Key)
+			}
+			if e.Key == "limit" {
+				opts.SetLimit(n)
+			} else {
+				opts.SetSkip(n)
+			}
+		default:
+			return nil, fmt.Errorf("find: unknown option '%s'. Supported: projection, sort, limit, skip", e.Key)
+		}
+	}
+	return opts, nil
+}
+
+func mongoAggregate(ctx context.Context, coll *mongo.Collection, q *mongoQuery) (*Result, error) {
+	if len(q.args) != 1 {
+		return nil, fmt.Errorf("aggregate: expected a pipeline like [{\"$match\": {...}}, {\"$group\": {...}}]")
+	}
+	pipeline, ok := q.args[0].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("aggregate: the pipeline must be a JSON array of stages")
+	}
+	for i, stage := range pipeline {
+		if _, ok := stage.(bson.D); !ok {
+			return nil, fmt.Errorf("aggregate: stage %d must be a JSON object", i+1)
+		}
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var docs []bson.D
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return documentsResult(docs), nil
+}
+
+func mongoDistinct(ctx context.Context, coll *mongo.Collection, q *mongoQuery) (*Result, error) {
+	if len(q.args) == 0 {
+		return nil, fmt.Errorf("distinct: expected a field name, e.g. distinct(\"status\", {filter})")
+	}
+	if err := q.maxArgs(2); err != nil {
+		return nil, err
+	}
+	field, ok := q.args[0].(string)
+	if !ok || field == "" {
+		return nil, fmt.Errorf("distinct: the field must be a string")
+	}
+	filter, err := q.document(1, "filter")
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := coll.Distinct(ctx, field, filter)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]interface{}, len(values))
+	for i, v := range values {
+		rows[i] = []interface{}{mongoValue(v)}
+	}
+	return &Result{Columns: []string{field}, Rows: rows}, nil
+}
+
+// documentsResult flattens documents into a table. Embedded documents
+// become dotted columns such as address.city, columns are ordered by first
+// appearance and fields missing from a document are nil.
+func documentsResult(docs []bson.D) *Result {
+	var columns []string
+	index := make(map[string]int)
+	flat := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		flat[i] = make(map[string]interface{})
+		flattenDocument("", doc, func(key string, value interface{}) {
+			if _, ok := index[key]; !ok {
+				index[key] = len(columns)
+				columns = append(columns, key)
+			}
+			flat[i][key] = value
+		})
+	}
+
+	rows := make([][]interface{}, len(flat))
+	for i, fields := range flat {
+		row := make([]interface{}, len(columns))
+		for key, value := range fields {
+			row[index[key]] = value
+		}
+		rows[i] = row
+	}
+	return &Result{Columns: columns, Rows: rows}
+}
+
+func flattenDocument(prefix string, doc bson.D, add func(key string, value interface{})) {
+	for _, e := range doc {
+		key := prefix + e.Key
+		if embedded, ok := e.Value.(bson.D); ok && len(embedded) > 0 {
+			flattenDocument(key+".", embedded, add)
+			continue
+		}
+		add(key, mongoValue(e.Value))
+	}
+}
+
+// mongoValue converts BSON values to the types other databases return:
+// int64 for integers, time.Time for dates and strings for object IDs,
+// decimals, arrays and documents
+func mongoValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int32:
+		return int64(v)
+	case primitive.DateTime:
+		return v.Time()
+	case primitive.Timestamp:
+		return time.Unix(int64(v.T), 0)
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.Decimal128:
+		return v.String()
+	case bson.D:
+		b, err := bson.MarshalExtJSON(v, false, false)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	case bson.A:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprintf("%v", mongoValue(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return v
+	}
+}
+
+// wholeNumber converts a JSON number without a fraction to int64
+func wholeNumber(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, false
+		}
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// shellHelper matches the mongosh constructors that have an Extended JSON
+// equivalent, e.g. NumberLong(5) or ObjectId("...")
+var shellHelper = regexp.MustCompile(`^(ObjectId|ISODate|NumberLong|NumberInt|NumberDecimal)\s*\(\s*("(?:[^"\\]|\\.)*"|[-+0-9.eE]+)\s*\)`)
+
+// shellTypes are the Extended JSON keys of the mongosh constructors
+var shellTypes = map[string]string{
+	"ObjectId":      "$oid",
+	"ISODate":       "$date",
+	"NumberLong":    "$numberLong",
+	"NumberInt":     "$numberInt",
+	"NumberDecimal": "$numberDecimal",
+}
+
+// shellToExtJSON rewrites mongosh constructors outside of strings to
+// Extended JSON, e.g. NumberLong(5) to {"$numberLong": "5"}, so queries
+// copied from the shell parse
+func shellToExtJSON(s string) string {
+	var b strings.Builder
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			b.WriteByte(c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+			b.WriteByte(c)
+			continue
+		}
+		if i == 0 || !isIdentifierByte(s[i-1]) {
+			if m := shellHelper.FindStringSubmatch(s[i:]); m != nil {
+				value := strings.Trim(m[2], `"`)
+				if m[1] == "ISODate" {
+					if t, err := time.Parse("2006-01-02", value); err == nil {
+						value = t.Format(time.RFC3339)
+					}
+				}
+				b.WriteString(fmt.Sprintf("{%q: %q}", shellTypes[m[1]], value))
+				i += len(m[0]) - 1
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// relativeDatePattern matches relative dates such as "$$NOW-24h" or
+// "$$NOW - 7d". A plain "$$NOW" is left to the server, which has the same
+// variable in aggregation expressions.
+var relativeDatePattern = regexp.MustCompile(`^\$\$NOW\s*([+-])\s*(\S+)$`)
+
+// relativeDate resolves a relative date against now. ok is false for
+// strings that are not relative dates.
+func relativeDate(s string, now time.Time) (t time.Time, ok bool, err error) {
+	m := relativeDatePattern.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, false, nil
+	}
+	offset, err := config.ParseAge(m[2])
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("invalid relative date '%s': %w", s, err)
+	}
+	if m[1] == "-" {
+		offset = -offset
+	}
+	return now.Add(offset), true, nil
+}
+
+// convertDates replaces date strings anywhere in a filter or pipeline with
+// dates: ISO dates such as "2024-01-01" or "2024-01-01T09:30:00Z" and
+// relative dates such as "$$NOW-24h"
+func convertDates(v interface{}, now time.Time) (interface{}, error) {
+	var err error
+	switch v := v.(type) {
+	case bson.D:
+		for i := range v {
+			if v[i].Value, err = convertDates(v[i].Value, now); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case bson.A:
+		for i := range v {
+			if v[i], err = convertDates(v[i], now); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case string:
+		if t, ok, err := relativeDate(v, now); ok {
+			return t, err
+		}
+		for _, layout := range []string{"2006-01-02", time.RFC3339} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testNow = time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func TestShellToExtJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"_id": ObjectId("65a1b2c3d4e5f6a7b8c9d0e1")}`, `{"_id": {"$oid": "65a1b2c3d4e5f6a7b8c9d0e1"}}`},
+		{`{"at": ISODate("2024-01-01")}`, `{"at": {"$date": "2024-01-01T00:00:00Z"}}`},
+		{`{"at": ISODate("2024-01-01T09:30:00Z")}`, `{"at": {"$date": "2024-01-01T09:30:00Z"}}`},
+		{`{"n": NumberLong(5)}`, `{"n": {"$numberLong": "5"}}`},
+		{`{"n": NumberLong("5")}`, `{"n": {"$numberLong": "5"}}`},
+		{`{"n": NumberInt( 3 )}`, `{"n": {"$numberInt": "3"}}`},
+		{`{"n": NumberDecimal("1.5")}`, `{"n": {"$numberDecimal": "1.5"}}`},
+		{`{"note": "ObjectId(\"x\")"}`, `{"note": "ObjectId(\"x\")"}`},
+		{`{"f": myObjectId("x")}`, `{"f": myObjectId("x")}`},
+		{`{"status": "active"}`, `{"status": "active"}`},
+	}
+	for _, tt := range tests {
+		if got := shellToExtJSON(tt.in); got != tt.want {
+			t.Errorf("shellToExtJSON(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMongoArgs(t *testing.T) {
+	oid, _ := primitive.ObjectIDFromHex("65a1b2c3d4e5f6a7b8c9d0e1")
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		in   string
+		want bson.A
+	}{
+		{"empty", ``, nil},
+		{"$date", `{"at": {"$gte": {"$date": "2024-01-01T00:00:00Z"}}}`,
+			bson.A{bson.D{{Key: "at", Value: bson.D{{Key: "$gte", Value: primitive.NewDateTimeFromTime(day)}}}}}},
+		{"$oid", `{"_id": {"$oid": "65a1b2c3d4e5f6a7b8c9d0e1"}}`,
+			bson.A{bson.D{{Key: "_id", Value: oid}}}},
+		{"ObjectId", `{"_id": ObjectId("65a1b2c3d4e5f6a7b8c9d0e1")}`,
+			bson.A{bson.D{{Key: "_id", Value: oid}}}},
+		{"NumberLong", `{"n": NumberLong(5000000000)}`,
+			bson.A{bson.D{{Key: "n", Value: int64(5000000000)}}}},
+		{"ISODate", `{"at": ISODate("2024-01-01")}`,
+			bson.A{bson.D{{Key: "at", Value: primitive.NewDateTimeFromTime(day)}}}},
+		{"ISO string", `{"at": {"$gte": "2024-01-01"}}`,
+			bson.A{bson.D{{Key: "at", Value: bson.D{{Key: "$gte", Value: day}}}}}},
+		{"RFC3339 string", `{"at": "2024-01-01T09:30:00Z"}`,
+			bson.A{bson.D{{Key: "at", Value: time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)}}}},
+		{"$$NOW-24h", `{"at": {"$gte": "$$NOW-24h"}}`,
+			bson.A{bson.D{{Key: "at", Value: bson.D{{Key: "$gte", Value: testNow.Add(-24 * time.Hour)}}}}}},
+		{"$$NOW+1h", `{"at": {"$lt": "$$NOW + 1h"}}`,
+			bson.A{bson.D{{Key: "at", Value: bson.D{{Key: "$lt", Value: testNow.Add(time.Hour)}}}}}},
+		{"$$NOW is left to the server", `{"$expr": {"$lt": ["$at", "$$NOW"]}}`,
+			bson.A{bson.D{{Key: "$expr", Value: bson.D{{Key: "$lt", Value: bson.A{"$at", "$$NOW"}}}}}}},
+		{"nested in $and and $or", `{"$and": [{"$or": [{"at": {"$gte": "2024-01-01"}}, {"at": {"$gte": "$$NOW-7d"}}]}, {"status": "paid"}]}`,
+			bson.A{bson.D{{Key: "$and", Value: bson.A{
+				bson.D{{Key: "$or", Value: bson.A{
+					bson.D{{Key: "at", Value: bson.D{{Key: "$gte", Value: day}}}},
+					bson.D{{Key: "at", Value: bson.D{{Key: "$gte", Value: testNow.AddDate(0, 0, -7)}}}},
+				}}},
+				bson.D{{Key: "status", Value: "paid"}},
+			}}}}},
+		{"several arguments", `"plan", {"status": "active"}`,
+			bson.A{"plan", bson.D{{Key: "status", Value: "active"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := parseMongoArgs(tt.in)
+			if err != nil {
+				t.Fatalf("parseMongoArgs: %v", err)
+			}
+			if _, err := convertDates(args, testNow); err != nil {
+				t.Fatalf("convertDates: %v", err)
+			}
+			if !reflect.DeepEqual(args, tt.want) {
+				t.Errorf("got %#v, want %#v", args, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMongoArgsErrors(t *testing.T) {
+	for _, in := range []string{
+		`{"status": }`,
+		`{status: "active"}`,
+		`{"status": 'active'}`,
+		`{"a": 1`,
+	} {
+		if _, err := parseMongoArgs(in); err == nil {
+			t.Errorf("parseMongoArgs(%s): expected an error", in)
+		}
+	}
+
+	args, err := parseMongoArgs(`{"at": {"$gte": "$$NOW-soon"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := convertDates(args, testNow); err == nil {
+		t.Error("convertDates: expected an error for an invalid relative date")
+	}
+}
+
+func TestParseMongoQuery(t *testing.T) {
+	tests := []struct {
+		in         string
+		collection string
+		operation  string
+	}{
+		{`users.count({})`, "users", "count"},
+		{`logs.errors.count({"level": "error"})`, "logs.errors", "count"},
+		{`  orders.estimatedDocumentCount()  `, "orders", "estimatedDocumentCount"},
+	}
+	for _, tt := range tests {
+		q, err := parseMongoQuery(tt.in)
+		if err != nil {
+			t.Errorf("parseMongoQuery(%s): %v", tt.in, err)
+			continue
+		}
+		if q.collection != tt.collection || q.operation != tt.operation {
+			t.Errorf("parseMongoQuery(%s) = %s.%s, want %s.%s", tt.in, q.collection, q.operation, tt.collection, tt.operation)
+		}
+	}
+
+	for _, in := range []string{`users`, `users.count`, `count({})`, `users.({})`, `users.count({"a": })`} {
+		if _, err := parseMongoQuery(in); err == nil {
+			t.Errorf("parseMongoQuery(%s): expected an error", in)
+		}
+	}
+}
+
+func TestShiftMongoQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`users.count({"created_at": {"$gte": "2024-06-15"}})`,
+			`users.count({"created_at":{"$gte":"2024-06-14","$lt":"2024-06-14T12:00:00Z"}})`},
+		{`logs.errors.count({"at": {"$gte": "$$NOW-24h"}})`,
+			`logs.errors.count({"at":{"$gte":"2024-06-13T12:00:00Z","$lt":"2024-06-14T12:00:00Z"}})`},
+		{`users.count({"at": {"$gte": ISODate("2024-06-15"), "$lt": ISODate("2024-06-16")}})`,
+			`users.count({"at":{"$gte":{"$date":"2024-06-14T00:00:00Z"},"$lt":{"$date":"2024-06-15T00:00:00Z"}}})`},
+		{`users.count({"$or": [{"at": {"$lte": "2024-06-15"}}]})`,
+			`users.count({"$or":[{"at":{"$lte":"2024-06-14"}}]})`},
+	}
+	for _, tt := range tests {
+		got, err := shiftMongoQuery(tt.in, 1, testNow)
+		if err != nil {
+			t.Errorf("shiftMongoQuery(%s): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("shiftMongoQuery(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{
+		`users.count({"status": "active"})`,
+		`users.find({"at": {"$gte": "2024-06-15"}})`,
+		`users.count({"at": })`,
+	} {
+		if _, err := shiftMongoQuery(in, 1, testNow); err == nil {
+			t.Errorf("shiftMongoQuery(%s): expected an error", in)
+		}
+	}
+}
+
+// TestMongoQueries runs queries on a real server. It connects to
+// DASHMIN_TEST_MONGODB_URI, or a local mongod, and is skipped when none is
+// reachable.
+func TestMongoQueries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping MongoDB integration test in short mode")
+	}
+	uri := os.Getenv("DASHMIN_TEST_MONGODB_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017/dashmin_test?serverSelectionTimeoutMS=2000"
+	}
+	conn, err := ConnectMongoDB(uri)
+	if err != nil {
+		t.Skipf("no MongoDB server reachable: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	mc := conn.(*MongoConnection)
+	coll := mc.client.Database(mc.dbName).Collection("dashmin_test_events")
+	ctx := context.Background()
+	_ = coll.Drop(ctx)
+	defer func() { _ = coll.Drop(ctx) }()
+
+	now := time.Now().UTC()
+	_, err = coll.InsertMany(ctx, []interface{}{
+		bson.D{{Key: "type", Value: "error"}, {Key: "level", Value: int32(5)}, {Key: "amount", Value: 10}, {Key: "at", Value: now.Add(-time.Hour)}},
+		bson.D{{Key: "type", Value: "error"}, {Key: "level", Value: int32(3)}, {Key: "amount", Value: 20}, {Key: "at", Value: now.Add(-48 * time.Hour)}},
+		bson.D{{Key: "type", Value: "info"}, {Key: "level", Value: int32(1)}, {Key: "amount", Value: 30}, {Key: "at", Value: now.Add(-2 * time.Hour)}},
+	})
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  interface{}
+	}{
+		{`dashmin_test_events.count({})`, int64(3)},
+		{`dashmin_test_events.count({"at": {"$gte": "$$NOW-24h"}})`, int64(2)},
+		{`dashmin_test_events.count({"$or": [{"type": "info"}, {"level": NumberInt(5)}], "at": {"$gte": "$$NOW-24h"}})`, int64(2)},
+		{`dashmin_test_events.count({"$and": [{"type": "error"}, {"at": {"$lt": "$$NOW-1d"}}]})`, int64(1)},
+		{`dashmin_test_events.find({"type": "error"}, {"projection": {"amount": 1, "_id": 0}, "sort": {"amount": -1}, "limit": 1})`, int64(20)},
+		{`dashmin_test_events.aggregate([{"$group": {"_id": null, "total": {"$sum": "$amount"}}}, {"$project": {"_id": 0, "total": 1}}])`, int64(60)},
+		{`dashmin_test_events.distinct("type", {"at": {"$gte": "$$NOW-24h"}})`, "error"},
+	}
+	for _, tt := range tests {
+		result, err := conn.Query(tt.query)
+		if err == nil {
+			err = result.Error
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if len(result.Rows) == 0 || len(result.Rows[0]) == 0 {
+			t.Errorf("%s: no rows", tt.query)
+			continue
+		}
+		if got := result.Rows[0][0]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %#v, want %#v", tt.query, got, tt.want)
+		}
+	}
+}
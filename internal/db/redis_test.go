@@ -0,0 +1,234 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testRedis starts an in-process Redis with a key of every counted type
+func testRedis(t *testing.T) (*miniredis.Miniredis, Connection) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	must := func(_ interface{}, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(nil, mr.Set("counter", "42"))
+	must(nil, mr.Set("ratio", "0.75"))
+	must(nil, mr.Set("greeting", "hello world"))
+	must(nil, mr.Set("my key", "spaced"))
+	must(mr.Push("queue:emails", "a", "b", "c"))
+	must(mr.SAdd("online", "alice", "bob"))
+	must(mr.ZAdd("leaderboard", 1, "alice"))
+	must(mr.PfAdd("visitors", "alice", "bob", "carol", "dave"))
+	must(mr.PfAdd("visitors:eu", "erin"))
+	must(mr.XAdd("events", "*", []string{"type", "signup"}))
+
+	conn, err := ConnectRedis("redis://" + mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return mr, conn
+}
+
+func TestRedisQueries(t *testing.T) {
+	_, conn := testRedis(t)
+
+	tests := []struct {
+		query  string
+		column string
+		want   interface{}
+	}{
+		{"DBSIZE", "dbsize", int64(10)},
+		{"dbsize", "dbsize", int64(10)},
+		{"LLEN queue:emails", "llen", int64(3)},
+		{"SCARD online", "scard", int64(2)},
+		{"ZCARD leaderboard", "zcard", int64(1)},
+		{"PFCOUNT visitors", "pfcount", int64(4)},
+		{"PFCOUNT visitors visitors:eu", "pfcount", int64(5)},
+		{"XLEN events", "xlen", int64(1)},
+		{"LLEN missing", "llen", int64(0)},
+		{"GET counter", "counter", int64(42)},
+		{"GET ratio", "ratio", 0.75},
+		{"GET greeting", "greeting", "hello world"},
+		{`GET "my key"`, "my key", "spaced"},
+		{"GET missing", "missing", nil},
+		{"INFO clients connected_clients", "connected_clients", int64(1)},
+	}
+	for _, tt := range tests {
+		result, err := conn.Query(tt.query)
+		if err == nil {
+			err = result.Error
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		want := &Result{Columns: []string{tt.column}, Rows: [][]interface{}{{tt.want}}}
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("%s = %v %v, want %v %v", tt.query, result.Columns, result.Rows, want.Columns, want.Rows)
+		}
+	}
+}
+
+func TestRedisRejectsWrites(t *testing.T) {
+	mr, conn := testRedis(t)
+
+	for _, query := range []string{
+		"SET counter 0",
+		"set counter 0",
+		"DEL counter",
+		"FLUSHALL",
+		"FLUSHDB",
+		"INCR counter",
+		"CONFIG SET maxmemory 1",
+		`EVAL "return redis.call('DEL', 'counter')" 0`,
+		"KEYS *",
+	} {
+		result, err := conn.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if result.Error == nil || !strings.Contains(result.Error.Error(), "only read-only commands are allowed") {
+			t.Errorf("%s: expected it to be rejected, got %v", query, result.Error)
+		}
+	}
+
+	if got, _ := mr.Get("counter"); got != "42" {
+		t.Errorf("counter = %q after rejected writes, want 42", got)
+	}
+	if !mr.Exists("queue:emails") {
+		t.Error("queue:emails was deleted by a rejected write")
+	}
+}
+
+func TestRedisArgumentCounts(t *testing.T) {
+	_, conn := testRedis(t)
+
+	for _, query := range []string{
+		"DBSIZE extra",
+		"LLEN",
+		"LLEN a b",
+		"SCARD",
+		"ZCARD a b",
+		"XLEN",
+		"PFCOUNT",
+		"GET",
+		"GET a b",
+		"INFO memory used_memory extra",
+	} {
+		result, err := conn.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if result.Error == nil || !strings.Contains(result.Error.Error(), "wrong number of arguments") {
+			t.Errorf("%s: expected an argument count error, got %v", query, result.Error)
+		}
+	}
+
+	result, err := conn.Query("   ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "empty Redis command") {
+		t.Errorf("empty command: got %v", result.Error)
+	}
+}
+
+func TestInfoResult(t *testing.T) {
+	info := "# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\nmem_fragmentation_ratio:1.25\r\n\r\n"
+
+	tests := []struct {
+		args []string
+		want *Result
+	}{
+		{[]string{"memory", "used_memory"}, &Result{Columns: []string{"used_memory"}, Rows: [][]interface{}{{int64(1048576)}}}},
+		{[]string{"memory", "used_memory_human"}, &Result{Columns: []string{"used_memory_human"}, Rows: [][]interface{}{{"1.00M"}}}},
+		{[]string{"memory", "mem_fragmentation_ratio"}, &Result{Columns: []string{"mem_fragmentation_ratio"}, Rows: [][]interface{}{{1.25}}}},
+		{[]string{"memory"}, &Result{Columns: []string{"field", "value"}, Rows: [][]interface{}{
+			{"used_memory", int64(1048576)},
+			{"used_memory_human", "1.00M"},
+			{"mem_fragmentation_ratio", 1.25},
+		}}},
+	}
+	for _, tt := range tests {
+		got, err := infoResult(info, tt.args)
+		if err != nil {
+			t.Errorf("INFO %v: %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("INFO %v = %v %v, want %v %v", tt.args, got.Columns, got.Rows, tt.want.Columns, tt.want.Rows)
+		}
+	}
+
+	_, err := infoResult(info, []string{"memory", "used_memory_peak"})
+	if err == nil || !strings.Contains(err.Error(), "field 'used_memory_peak' not found in INFO memory") {
+		t.Errorf("missing field: got %v", err)
+	}
+}
+
+func TestRedisValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want interface{}
+	}{
+		{"42", int64(42)},
+		{"-7", int64(-7)},
+		{"0", int64(0)},
+		{"1.25", 1.25},
+		{"1e3", 1000.0},
+		{"9223372036854775808", 9223372036854775808.0},
+		{"1.00M", "1.00M"},
+		{"", ""},
+		{"hello", "hello"},
+		{"inf", "inf"},
+		{"-Infinity", "-Infinity"},
+		{"NaN", "NaN"},
+	}
+	for _, tt := range tests {
+		if got := redisValue(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("redisValue(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitRedisCommand(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"LLEN queue:emails", []string{"LLEN", "queue:emails"}},
+		{"  INFO\tmemory   used_memory  ", []string{"INFO", "memory", "used_memory"}},
+		{`GET "my key"`, []string{"GET", "my key"}},
+		{`GET 'my key'`, []string{"GET", "my key"}},
+		{`GET "say \"hi\""`, []string{"GET", `say "hi"`}},
+		{`GET 'it\s'`, []string{"GET", `it\s`}},
+		{`GET "it's"`, []string{"GET", "it's"}},
+		{`GET ""`, []string{"GET", ""}},
+		{`GET pre"fix suffix"`, []string{"GET", "prefix suffix"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := splitRedisCommand(tt.in)
+		if err != nil {
+			t.Errorf("splitRedisCommand(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitRedisCommand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{`GET "my key`, `GET 'my key`, `GET "escaped \"`} {
+		if _, err := splitRedisCommand(in); err == nil {
+			t.Errorf("splitRedisCommand(%q): expected an unterminated quote error", in)
+		}
+	}
+}
@@ -1,11 +1,86 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
 )
 
+// Schema lists the tables of a database and their columns. Only the tables
+// of one schema are read: public on PostgreSQL, the connected database on
+// MySQL and main on SQLite. Name is that schema, empty for MongoDB.
+type Schema struct {
+	Name   string
+	Tables []Table
+}
+
+// Table is a table, or a collection for MongoDB. Columns is nil when they
+// are not known, as for MongoDB collections.
+type Table struct {
+	Name    string
+	Columns []Column
+}
+
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+// Table finds a table by name. Names are matched case-insensitively like
+// unquoted SQL identifiers, and may be qualified with the schema name, e.g.
+// public.users.
+func (s *Schema) Table(name string) (Table, bool) {
+	for _, t := range s.Tables {
+		if strings.EqualFold(t.Name, name) {
+			return t, true
+		}
+	}
+	if qualifier, bare, ok := strings.Cut(name, "."); ok && s.Name != "" && strings.EqualFold(qualifier, s.Name) {
+		return s.Table(bare)
+	}
+	return Table{}, false
+}
+
+// Column finds a column of the table by name, case-insensitively
+func (t Table) Column(name string) (Column, bool) {
+	for _, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// GetDatabaseSchema describes the database in a form suitable for the AI
+// query generator
 func GetDatabaseSchema(conn Connection, dbType string) (string, error) {
+	switch dbType {
+	case "mongodb":
+		return getMongoDBSchema(conn)
+	case "redis":
+		return getRedisSchema(), nil
+	}
+	schema, err := GetSchema(conn, dbType)
+	if err != nil {
+		return "", err
+	}
+	return formatSchema(schema, schemaNames[dbType]), nil
+}
+
+// schemaNames are the database names used in schema descriptions
+var schemaNames = map[string]string{
+	"postgres": "PostgreSQL",
+	"mysql":    "MySQL",
+	"sqlite":   "SQLite",
+}
+
+// GetSchema reads the tables and columns of a database. For MongoDB, only
+// the collection names are known.
+func GetSchema(conn Connection, dbType string) (*Schema, error) {
 	switch dbType {
 	case "postgres":
 		return getPostgresSchema(conn)
@@ -14,13 +89,13 @@ func GetDatabaseSchema(conn Connection, dbType string) (string, error) {
 	case "sqlite":
 		return getSQLiteSchema(conn)
 	case "mongodb":
-		return getMongoDBSchema(conn)
+		return getMongoDBCollections(conn)
 	default:
-		return "", fmt.Errorf("unsupported database type: %s", dbType)
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
 	}
 }
 
-func getPostgresSchema(conn Connection) (string, error) {
+func getPostgresSchema(conn Connection) (*Schema, error) {
 	query := `
 		SELECT
 			table_name,
@@ -34,17 +109,19 @@ func getPostgresSchema(conn Connection) (string, error) {
 
 	result, err := conn.Query(query)
 	if err != nil {
-		return "", fmt.Errorf("failed to get PostgreSQL schema: %w", err)
+		return nil, fmt.Errorf("failed to get PostgreSQL schema: %w", err)
 	}
 
 	if result.Error != nil {
-		return "", fmt.Errorf("schema query error: %w", result.Error)
+		return nil, fmt.Errorf("schema query error: %w", result.Error)
 	}
 
-	return formatSchemaResult(result, "PostgreSQL"), nil
+	schema := schemaFromResult(result)
+	schema.Name = "public"
+	return schema, nil
 }
 
-func getMySQLSchema(conn Connection) (string, error) {
+func getMySQLSchema(conn Connection) (*Schema, error) {
 	query := `
 		SELECT
 			table_name,
@@ -58,17 +135,25 @@ func getMySQLSchema(conn Connection) (string, error) {
 
 	result, err := conn.Query(query)
 	if err != nil {
-		return "", fmt.Errorf("failed to get MySQL schema: %w", err)
+		return nil, fmt.Errorf("failed to get MySQL schema: %w", err)
 	}
 
 	if result.Error != nil {
-		return "", fmt.Errorf("schema query error: %w", result.Error)
+		return nil, fmt.Errorf("schema query error: %w", result.Error)
 	}
 
-	return formatSchemaResult(result, "MySQL"), nil
+	schema := schemaFromResult(result)
+	name, err := conn.Query("SELECT DATABASE()")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MySQL database name: %w", err)
+	}
+	if name.Error == nil && len(name.Rows) > 0 && len(name.Rows[0]) > 0 && name.Rows[0][0] != nil {
+		schema.Name = fmt.Sprintf("%v", name.Rows[0][0])
+	}
+	return schema, nil
 }
 
-func getSQLiteSchema(conn Connection) (string, error) {
+func getSQLiteSchema(conn Connection) (*Schema, error) {
 	query := `
 		SELECT 
 			m.name as table_name,
@@ -83,14 +168,38 @@ func getSQLiteSchema(conn Connection) (string, error) {
 
 	result, err := conn.Query(query)
 	if err != nil {
-		return "", fmt.Errorf("failed to get SQLite schema: %w", err)
+		return nil, fmt.Errorf("failed to get SQLite schema: %w", err)
 	}
 
 	if result.Error != nil {
-		return "", fmt.Errorf("schema query error: %w", result.Error)
+		return nil, fmt.Errorf("schema query error: %w", result.Error)
+	}
+
+	schema := schemaFromResult(result)
+	schema.Name = "main"
+	return schema, nil
+}
+
+// getMongoDBCollections lists the collections of a MongoDB database
+func getMongoDBCollections(conn Connection) (*Schema, error) {
+	mongoConn, ok := conn.(*MongoConnection)
+	if !ok {
+		return nil, fmt.Errorf("not a MongoDB connection")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	names, err := mongoConn.client.Database(mongoConn.dbName).ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MongoDB collections: %w", err)
 	}
+	sort.Strings(names)
 
-	return formatSchemaResult(result, "SQLite"), nil
+	schema := &Schema{}
+	for _, name := range names {
+		schema.Tables = append(schema.Tables, Table{Name: name})
+	}
+	return schema, nil
 }
 
 func getMongoDBSchema(conn Connection) (string, error) {
@@ -104,39 +213,68 @@ Note: Field names vary by project. Common patterns:
 - updated_at OR updatedAt
 - date_created OR dateCreated
 
-IMPORTANT: Only count() operation is supported. Use JSON format.
-Query format: collection.count({filter})
+IMPORTANT: Use JSON format. Supported operations:
+- collection.count({filter})
+- collection.aggregate([pipeline])
+- collection.find({filter}, {"projection": {...}, "sort": {...}, "limit": N})
+- collection.distinct("field", {filter})
+- collection.estimatedDocumentCount()
 Examples:
 - users.count({"status": "active"})
-- users.count({"createdAt": {"$gte": "2024-01-01"}})`, nil
+- users.count({"createdAt": {"$gte": "2024-01-01"}})
+- orders.aggregate([{"$group": {"_id": null, "total": {"$sum": "$amount"}}}])`, nil
 }
 
-func formatSchemaResult(result *Result, dbType string) string {
-	var schema strings.Builder
-	schema.WriteString(fmt.Sprintf("%s Database Schema:\n\n", dbType))
+func getRedisSchema() string {
+	return `Redis has no schema. Only these read-only commands are supported, one per query:
+- DBSIZE: number of keys
+- INFO <section> <field>: one field of INFO, e.g. INFO memory used_memory
+- SCARD key, LLEN key, ZCARD key, XLEN key: size of a set, list, sorted set or stream
+- PFCOUNT key: HyperLogLog estimate
+- GET key: value of a key
+Examples:
+- LLEN queue:emails
+- INFO clients connected_clients`
+}
 
-	currentTable := ""
+// schemaFromResult builds a schema from rows of table name, column name,
+// data type and nullability ("YES" or "NO"), ordered by table
+func schemaFromResult(result *Result) *Schema {
+	schema := &Schema{}
 	for _, row := range result.Rows {
 		tableName := fmt.Sprintf("%v", row[0])
-		columnName := fmt.Sprintf("%v", row[1])
-		dataType := fmt.Sprintf("%v", row[2])
-		nullable := fmt.Sprintf("%v", row[3])
-
-		if tableName != currentTable {
-			if currentTable != "" {
-				schema.WriteString("\n")
-			}
-			schema.WriteString(fmt.Sprintf("Table: %s\n", tableName))
-			currentTable = tableName
+		column := Column{
+			Name:     fmt.Sprintf("%v", row[1]),
+			Type:     fmt.Sprintf("%v", row[2]),
+			Nullable: fmt.Sprintf("%v", row[3]) == "YES",
 		}
 
-		nullableStr := ""
-		if nullable == "YES" {
-			nullableStr = " (nullable)"
+		if n := len(schema.Tables); n == 0 || schema.Tables[n-1].Name != tableName {
+			schema.Tables = append(schema.Tables, Table{Name: tableName})
 		}
+		table := &schema.Tables[len(schema.Tables)-1]
+		table.Columns = append(table.Columns, column)
+	}
+	return schema
+}
+
+func formatSchema(schema *Schema, dbType string) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s Database Schema:\n\n", dbType))
 
-		schema.WriteString(fmt.Sprintf("  - %s: %s%s\n", columnName, dataType, nullableStr))
+	for i, table := range schema.Tables {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(fmt.Sprintf("Table: %s\n", table.Name))
+		for _, column := range table.Columns {
+			nullableStr := ""
+			if column.Nullable {
+				nullableStr = " (nullable)"
+			}
+			b.WriteString(fmt.Sprintf("  - %s: %s%s\n", column.Name, column.Type, nullableStr))
+		}
 	}
 
-	return schema.String()
+	return b.String()
 }
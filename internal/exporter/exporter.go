@@ -0,0 +1,266 @@
+// Package exporter exposes query results as Prometheus metrics
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "dashmin"
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Exporter is a prometheus.Collector that runs the configured queries on
+// scrapes. Queries with an interval only run once it has elapsed and are
+// served from their last result in between; the others run on each scrape.
+// Numeric columns become dashmin_query_value gauges and non-numeric columns
+// become extra labels, so a query returning several rows produces one
+// series per row.
+type Exporter struct {
+	ctx       context.Context
+	cfg       *config.Config
+	filterApp string
+	conns     *collector.Manager
+
+	// mu serializes scrapes, which share the schedule and the last result
+	// of each query
+	mu       sync.Mutex
+	schedule *collector.Schedule
+	last     map[queryKey]collector.QueryResult
+
+	success  *prometheus.Desc
+	duration *prometheus.HistogramVec
+}
+
+// New creates an exporter that queries apps through conns, which keeps
+// connections open between scrapes. Canceling ctx aborts running queries,
+// e.g. when the server shuts down.
+func New(ctx context.Context, cfg *config.Config, filterApp string, conns *collector.Manager) *Exporter {
+	return &Exporter{
+		ctx:       ctx,
+		cfg:       cfg,
+		filterApp: filterApp,
+		conns:     conns,
+		schedule:  collector.NewSchedule(cfg, filterApp, 0),
+		last:      make(map[queryKey]collector.QueryResult),
+		success: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "query", "success"),
+			"Whether the last run of the query succeeded (1) or failed (0).",
+			[]string{"app", "query"}, nil,
+		),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "query",
+			Name:      "duration_seconds",
+			Help:      "Time spent running the query.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"app", "query"}),
+	}
+}
+
+// Describe sends no descriptors, which makes the exporter an unchecked
+// collector. Value metrics carry per-query labels that are only known after
+// the queries ran.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {}
+
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, r := range e.refresh() {
+		if r.Duration > 0 {
+			e.duration.WithLabelValues(r.AppName, r.QueryLabel).Observe(r.Duration.Seconds())
+		}
+	}
+
+	for _, r := range e.results() {
+		success := 1.0
+		if r.Result.Error != nil {
+			success = 0
+		}
+		ch <- prometheus.MustNewConstMetric(e.success, prometheus.GaugeValue, success, r.AppName, r.QueryLabel)
+
+		if r.Result.Error == nil {
+			e.collectValues(ch, r)
+		}
+	}
+
+	e.duration.Collect(ch)
+}
+
+// refresh runs the queries that are due or have no interval and keeps
+// their results, returning the fresh ones
+func (e *Exporter) refresh() []collector.QueryResult {
+	now := time.Now()
+	sel := e.schedule.Due(now)
+	for appName, labels := range e.schedule.Unscheduled() {
+		sel[appName] = append(sel[appName], labels...)
+	}
+	e.schedule.Ran(sel, now)
+
+	out := make(chan collector.QueryResult)
+	go collector.Stream(e.ctx, e.cfg, sel, e.conns, out)
+
+	var fresh []collector.QueryResult
+	for r := range out {
+		fresh = append(fresh, r)
+		if r.QueryLabel == collector.ConnectionLabel {
+			// None of the app's selected queries ran; drop their old results
+			// rather than serving them as if they were current
+			for _, label := range sel[r.AppName] {
+				delete(e.last, resultKey(r.AppName, label))
+			}
+		} else {
+			delete(e.last, resultKey(r.AppName, collector.ConnectionLabel))
+		}
+		e.last[resultKey(r.AppName, r.QueryLabel)] = r
+	}
+	return fresh
+}
+
+// results returns the last result of each query in collector.Collect order
+func (e *Exporter) results() []collector.QueryResult {
+	var results []collector.QueryResult
+	for _, appName := range collector.AppNames(e.cfg, e.filterApp) {
+		labels := append([]string{collector.ConnectionLabel}, e.cfg.Apps[appName].SortedLabels()...)
+		for _, label := range labels {
+			if r, ok := e.last[resultKey(appName, label)]; ok {
+				results = append(results, r)
+			}
+		}
+	}
+	return results
+}
+
+// queryKey identifies the last result of a query. A struct rather than a
+// joined string keeps app "a/b", query "c" apart from app "a", query "b/c".
+type queryKey struct {
+	app, label string
+}
+
+func resultKey(appName, label string) queryKey {
+	return queryKey{app: appName, label: label}
+}
+
+// collectValues emits one gauge per numeric cell of the result
+func (e *Exporter) collectValues(ch chan<- prometheus.Metric, r collector.QueryResult) {
+	columns := r.Result.Columns
+	if len(r.Result.Rows) == 0 {
+		return
+	}
+
+	// Decide from the first row which columns are values and which are labels
+	var valueCols, labelCols []int
+	for i, val := range r.Result.Rows[0] {
+		if _, ok := toFloat(val); ok {
+			valueCols = append(valueCols, i)
+		} else {
+			labelCols = append(labelCols, i)
+		}
+	}
+
+	var extraLabels []string
+	for _, i := range labelCols {
+		extraLabels = append(extraLabels, columnName(columns, i))
+	}
+	names := append([]string{"app", "query"}, labelNames(extraLabels)...)
+	if len(valueCols) > 1 {
+		names = append(names, "column")
+	}
+	desc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "query", "value"),
+		"Value returned by the query. Non-numeric columns become labels.",
+		names, nil,
+	)
+
+	seen := make(map[string]bool)
+	for _, row := range r.Result.Rows {
+		labelValues := []string{r.AppName, r.QueryLabel}
+		for _, i := range labelCols {
+			labelValues = append(labelValues, labelValue(row, i))
+		}
+
+		for _, i := range valueCols {
+			if i >= len(row) {
+				continue
+			}
+			value, ok := toFloat(row[i])
+			if !ok {
+				continue
+			}
+			values := labelValues
+			if len(valueCols) > 1 {
+				values = append(append([]string{}, labelValues...), columnName(columns, i))
+			}
+
+			// Duplicate label sets would make the whole scrape fail
+			key := strings.Join(values, "\xff")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, value, values...)
+			if err != nil {
+				// e.g. a label value that is not valid UTF-8
+				continue
+			}
+			ch <- metric
+		}
+	}
+}
+
+func toFloat(val interface{}) (float64, bool) {
+	if b, ok := val.(bool); ok {
+		if b {
+			return 1, true
+		}
+		return 0, true
+	}
+	return collector.ToFloat(val)
+}
+
+func columnName(columns []string, i int) string {
+	if i < len(columns) && columns[i] != "" {
+		return columns[i]
+	}
+	return fmt.Sprintf("column_%d", i)
+}
+
+func labelValue(row []interface{}, i int) string {
+	if i >= len(row) || row[i] == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", row[i])
+}
+
+// labelNames turns column names into valid, unique Prometheus label names.
+// Columns clashing with the built-in labels get an "exported_" prefix.
+func labelNames(columns []string) []string {
+	used := map[string]bool{"app": true, "query": true, "column": true}
+	names := make([]string, 0, len(columns))
+	for _, col := range columns {
+		name := invalidLabelChars.ReplaceAllString(col, "_")
+		name = strings.TrimLeft(name, "_")
+		if name == "" || (name[0] >= '0' && name[0] <= '9') {
+			name = "column_" + name
+		}
+		if used[name] {
+			name = "exported_" + name
+		}
+		for base, n := name, 2; used[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[name] = true
+		names = append(names, name)
+	}
+	return names
+}
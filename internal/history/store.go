@@ -0,0 +1,151 @@
+// Package history records query results in a local SQLite database so past
+// values can be looked up later. It is not meant to replace a time-series
+// database: one row is stored per query run and old rows are pruned.
+package history
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/config"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS samples (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	app         TEXT NOT NULL,
+	label       TEXT NOT NULL,
+	value       REAL,
+	text        TEXT NOT NULL DEFAULT '',
+	error       TEXT NOT NULL DEFAULT '',
+	duration_ms REAL NOT NULL,
+	recorded_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS samples_app_label_time ON samples (app, label, recorded_at);
+CREATE INDEX IF NOT EXISTS samples_time ON samples (recorded_at);
+`
+
+// Sample is one recorded query run
+type Sample struct {
+	App        string
+	Label      string
+	Value      *float64 // nil for non-numeric values and errors
+	Text       string   // the value as displayed
+	Error      string
+	Duration   time.Duration
+	RecordedAt time.Time
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+// DefaultPath returns the history database path next to the config file
+func DefaultPath() string {
+	return filepath.Join(filepath.Dir(config.GetConfigPath()), "history.db")
+}
+
+// Open opens the history database at path, creating it if needed
+func Open(path string) (*Store, error) {
+	if err := config.EnsureConfigDir(); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	// WAL and a busy timeout let a dashboard and a cron job write concurrently
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to initialize history: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Record stores one sample per result
+func (s *Store) Record(results []collector.QueryResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.Prepare(`INSERT INTO samples (app, label, value, text, error, duration_ms, recorded_at) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, r := range results {
+		var value *float64
+		var text, errStr string
+		if r.Result.Error != nil {
+			errStr = r.Result.Error.Error()
+		} else if len(r.Result.Rows) > 0 && len(r.Result.Rows[0]) > 0 {
+			val := r.Result.Rows[0][0]
+			text = fmt.Sprintf("%v", val)
+			if f, ok := collector.ToFloat(val); ok {
+				value = &f
+			}
+		}
+
+		durationMs := float64(r.Duration.Microseconds()) / 1000
+		if _, err := stmt.Exec(r.AppName, r.QueryLabel, value, text, errStr, durationMs, r.LastUpdated.UnixMilli()); err != nil {
+			return fmt.Errorf("failed to record history: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Samples returns the samples of one query recorded since the given time,
+// oldest first
+func (s *Store) Samples(app, label string, since time.Time) ([]Sample, error) {
+	rows, err := s.db.Query(`
+		SELECT value, text, error, duration_ms, recorded_at
+		FROM samples
+		WHERE app = ? AND label = ? AND recorded_at >= ?
+		ORDER BY recorded_at`, app, label, since.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var samples []Sample
+	for rows.Next() {
+		var value sql.NullFloat64
+		var durationMs float64
+		var recordedAt int64
+		sample := Sample{App: app, Label: label}
+		if err := rows.Scan(&value, &sample.Text, &sample.Error, &durationMs, &recordedAt); err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		if value.Valid {
+			sample.Value = &value.Float64
+		}
+		sample.Duration = time.Duration(durationMs * float64(time.Millisecond))
+		sample.RecordedAt = time.UnixMilli(recordedAt)
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
+
+// Prune deletes samples recorded before the given time and returns how many
+// were removed
+func (s *Store) Prune(before time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM samples WHERE recorded_at < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune history: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
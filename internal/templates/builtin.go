@@ -0,0 +1,79 @@
+package templates
+
+// builtins are the templates shipped with dashmin. Time windows use the
+// query macros so one SQL query works on every dialect and compare:
+// yesterday can move it back.
+var builtins = []Template{
+	{
+		Name:        "count",
+		Description: "Rows in [[.Table]]",
+		Params:      []string{ParamTable},
+		Queries: map[string]string{
+			"sql":     `SELECT COUNT(*) FROM [[.Table]]`,
+			"mongodb": `[[.Table]].count({})`,
+		},
+	},
+	{
+		Name:        "count-today",
+		Description: "Rows in [[.Table]] created today",
+		Params:      []string{ParamTable, ParamDateColumn},
+		Queries: map[string]string{
+			"sql":     `SELECT COUNT(*) FROM [[.Table]] WHERE [[.DateColumn]] >= {{.Today}} AND [[.DateColumn]] < {{.Now}}`,
+			"mongodb": `[[.Table]].count({"[[.DateColumn]]": {"$gte": {{.Today}}, "$lt": {{.Now}}}})`,
+		},
+	},
+	{
+		Name:        "count-24h",
+		Description: "Rows in [[.Table]] created in the last 24 hours",
+		Params:      []string{ParamTable, ParamDateColumn},
+		Queries: map[string]string{
+			"sql":     `SELECT COUNT(*) FROM [[.Table]] WHERE [[.DateColumn]] >= {{.Ago "24h"}}`,
+			"mongodb": `[[.Table]].count({"[[.DateColumn]]": {"$gte": {{.Ago "24h"}}}})`,
+		},
+	},
+	{
+		Name:        "count-this-week",
+		Description: "Rows in [[.Table]] created since Monday",
+		Params:      []string{ParamTable, ParamDateColumn},
+		Queries: map[string]string{
+			"sql":     `SELECT COUNT(*) FROM [[.Table]] WHERE [[.DateColumn]] >= {{.StartOfWeek}} AND [[.DateColumn]] < {{.Now}}`,
+			"mongodb": `[[.Table]].count({"[[.DateColumn]]": {"$gte": {{.StartOfWeek}}, "$lt": {{.Now}}}})`,
+		},
+	},
+	{
+		Name:        "sum",
+		Description: "Total [[.Column]] in [[.Table]]",
+		Params:      []string{ParamTable, ParamColumn},
+		Queries: map[string]string{
+			"sql":     `SELECT COALESCE(SUM([[.Column]]), 0) FROM [[.Table]]`,
+			"mongodb": `[[.Table]].aggregate([{"$group": {"_id": null, "total": {"$sum": "$[[.Column]]"}}}, {"$project": {"_id": 0, "total": 1}}])`,
+		},
+	},
+	{
+		Name:        "sum-today",
+		Description: "Total [[.Column]] in [[.Table]] today",
+		Params:      []string{ParamTable, ParamColumn, ParamDateColumn},
+		Queries: map[string]string{
+			"sql":     `SELECT COALESCE(SUM([[.Column]]), 0) FROM [[.Table]] WHERE [[.DateColumn]] >= {{.Today}} AND [[.DateColumn]] < {{.Now}}`,
+			"mongodb": `[[.Table]].aggregate([{"$match": {"[[.DateColumn]]": {"$gte": {{.Today}}, "$lt": {{.Now}}}}}, {"$group": {"_id": null, "total": {"$sum": "$[[.Column]]"}}}, {"$project": {"_id": 0, "total": 1}}])`,
+		},
+	},
+	{
+		Name:        "avg",
+		Description: "Average [[.Column]] in [[.Table]]",
+		Params:      []string{ParamTable, ParamColumn},
+		Queries: map[string]string{
+			"sql":     `SELECT AVG([[.Column]]) FROM [[.Table]]`,
+			"mongodb": `[[.Table]].aggregate([{"$group": {"_id": null, "average": {"$avg": "$[[.Column]]"}}}, {"$project": {"_id": 0, "average": 1}}])`,
+		},
+	},
+	{
+		Name:        "latest",
+		Description: "Most recent [[.DateColumn]] in [[.Table]]",
+		Params:      []string{ParamTable, ParamDateColumn},
+		Queries: map[string]string{
+			"sql":     `SELECT MAX([[.DateColumn]]) FROM [[.Table]]`,
+			"mongodb": `[[.Table]].aggregate([{"$group": {"_id": null, "latest": {"$max": "$[[.DateColumn]]"}}}, {"$project": {"_id": 0, "latest": 1}}])`,
+		},
+	},
+}
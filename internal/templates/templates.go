@@ -0,0 +1,319 @@
+// Package templates provides ready-made queries for common metrics, written
+// once per database dialect. Users can add their own as YAML files in the
+// templates directory next to the config file.
+package templates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/lucasnevespereira/dashmin/internal/db"
+	"gopkg.in/yaml.v3"
+)
+
+// Template parameters, as named in template files and flags
+const (
+	ParamTable      = "table"
+	ParamDateColumn = "date_column"
+	ParamColumn     = "column"
+)
+
+// Template is a query written for each dialect. Queries and the description
+// use [[ ]] for template parameters, e.g. [[.Table]], so the {{ }} time
+// macros are kept in the generated query and rendered when it runs.
+//
+// Queries are keyed by app type. "sql" is used for postgres, mysql and
+// sqlite when they have no query of their own.
+type Template struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Params      []string          `yaml:"params"`
+	Queries     map[string]string `yaml:"queries"`
+
+	// Path is the file a user template was loaded from, empty for built-ins
+	Path string `yaml:"-"`
+}
+
+// Params are the values filled into a template
+type Params struct {
+	Table      string
+	DateColumn string
+	Column     string
+}
+
+func (p Params) value(param string) string {
+	switch param {
+	case ParamTable:
+		return p.Table
+	case ParamDateColumn:
+		return p.DateColumn
+	case ParamColumn:
+		return p.Column
+	default:
+		return ""
+	}
+}
+
+var validParams = []string{ParamTable, ParamDateColumn, ParamColumn}
+
+// identifierPattern matches plain table and column names, optionally
+// qualified with a schema, e.g. public.users
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)?$`)
+
+// sqlTypes are the app types served by a template's "sql" query
+var sqlTypes = []string{"postgres", "mysql", "sqlite"}
+
+// allTypes are the app types templates can have a query for
+var allTypes = []string{"postgres", "mysql", "sqlite", "mongodb"}
+
+// Dir returns the directory user templates are loaded from
+func Dir() string {
+	return filepath.Join(filepath.Dir(config.GetConfigPath()), "templates")
+}
+
+// List returns the built-in and user templates sorted by name. A user
+// template replaces the built-in template of the same name.
+func List() ([]Template, error) {
+	byName := make(map[string]Template)
+	for _, t := range builtins {
+		byName[t.Name] = t
+	}
+	user, err := loadDir(Dir())
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range user {
+		byName[t.Name] = t
+	}
+
+	list := make([]Template, 0, len(byName))
+	for _, t := range byName {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Get finds a template by name
+func Get(name string) (Template, error) {
+	list, err := List()
+	if err != nil {
+		return Template{}, err
+	}
+	var names []string
+	for _, t := range list {
+		if t.Name == name {
+			return t, nil
+		}
+		names = append(names, t.Name)
+	}
+	return Template{}, fmt.Errorf("template '%s' not found. Available: %s", name, strings.Join(names, ", "))
+}
+
+// loadDir reads every .yaml or .yml file of dir as a template. A missing
+// directory has no templates.
+func loadDir(dir string) ([]Template, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading templates: %w", err)
+	}
+
+	var list []Template
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		t, err := loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+func loadFile(path string) (Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Template{}, fmt.Errorf("reading template: %w", err)
+	}
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return Template{}, fmt.Errorf("template %s: %w", path, err)
+	}
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	t.Path = path
+	if err := t.check(); err != nil {
+		return Template{}, fmt.Errorf("template %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// check reports mistakes in a template file
+func (t Template) check() error {
+	if len(t.Queries) == 0 {
+		return fmt.Errorf("no queries defined")
+	}
+	for _, param := range t.Params {
+		if !slices.Contains(validParams, param) {
+			return fmt.Errorf("unknown param '%s'. Supported: %s", param, strings.Join(validParams, ", "))
+		}
+	}
+	for dialect, query := range t.Queries {
+		if _, err := parse(query); err != nil {
+			return fmt.Errorf("query for %s: %w", dialect, err)
+		}
+	}
+	return nil
+}
+
+// Types returns the app types the template has a query for
+func (t Template) Types() []string {
+	var types []string
+	for _, dbType := range allTypes {
+		if _, ok := t.query(dbType); ok {
+			types = append(types, dbType)
+		}
+	}
+	return types
+}
+
+func (t Template) query(dbType string) (string, bool) {
+	if q, ok := t.Queries[dbType]; ok {
+		return q, true
+	}
+	if slices.Contains(sqlTypes, dbType) {
+		q, ok := t.Queries["sql"]
+		return q, ok
+	}
+	return "", false
+}
+
+// Render fills the parameters into the template's query for an app type,
+// returning the query and a description of it
+func (t Template) Render(dbType string, p Params) (query, description string, err error) {
+	text, ok := t.query(dbType)
+	if !ok {
+		return "", "", fmt.Errorf("template '%s' has no query for %s. Available for: %s", t.Name, dbType, strings.Join(t.Types(), ", "))
+	}
+	if err := t.checkParams(p); err != nil {
+		return "", "", err
+	}
+	if query, err = execute(text, p); err != nil {
+		return "", "", fmt.Errorf("template '%s': %w", t.Name, err)
+	}
+	if description, err = execute(t.Description, p); err != nil {
+		return "", "", fmt.Errorf("template '%s': %w", t.Name, err)
+	}
+	return query, description, nil
+}
+
+// checkParams makes sure every parameter the template needs is set to a
+// plain identifier
+func (t Template) checkParams(p Params) error {
+	var missing []string
+	for _, param := range t.Params {
+		value := p.value(param)
+		if value == "" {
+			missing = append(missing, flagName(param))
+			continue
+		}
+		if !identifierPattern.MatchString(value) {
+			return fmt.Errorf("invalid %s '%s': expected a table or column name", strings.ReplaceAll(param, "_", " "), value)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("template '%s' needs %s", t.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Flags returns the command line flags of the template's parameters
+func (t Template) Flags() []string {
+	flags := make([]string, len(t.Params))
+	for i, param := range t.Params {
+		flags[i] = flagName(param)
+	}
+	return flags
+}
+
+// Summary is the description with placeholders for the parameters, e.g.
+// "Rows in <table>"
+func (t Template) Summary() string {
+	summary, err := execute(t.Description, Params{Table: "<table>", DateColumn: "<date_column>", Column: "<column>"})
+	if err != nil {
+		return t.Description
+	}
+	return summary
+}
+
+func flagName(param string) string {
+	return "--" + strings.ReplaceAll(param, "_", "-")
+}
+
+// CheckSchema verifies that the table and columns of p exist in the schema.
+// Columns are only checked when the schema knows them, and tables of a
+// schema other than the one read, e.g. analytics.events, are not checked.
+func (t Template) CheckSchema(schema *db.Schema, p Params) error {
+	if !slices.Contains(t.Params, ParamTable) {
+		return nil
+	}
+	table, ok := schema.Table(p.Table)
+	if !ok {
+		if qualifier, _, found := strings.Cut(p.Table, "."); found && schema.Name != "" && !strings.EqualFold(qualifier, schema.Name) {
+			return nil
+		}
+		var names []string
+		for _, t := range schema.Tables {
+			names = append(names, t.Name)
+		}
+		return fmt.Errorf("table '%s' not found. Available: %s", p.Table, strings.Join(names, ", "))
+	}
+	if table.Columns == nil {
+		return nil
+	}
+	for _, param := range []string{ParamDateColumn, ParamColumn} {
+		name := p.value(param)
+		if !slices.Contains(t.Params, param) || name == "" {
+			continue
+		}
+		if _, ok := table.Column(name); !ok {
+			var names []string
+			for _, c := range table.Columns {
+				names = append(names, c.Name)
+			}
+			return fmt.Errorf("column '%s' not found in table '%s'. Available: %s", name, table.Name, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+func parse(text string) (*template.Template, error) {
+	return template.New("template").Delims("[[", "]]").Option("missingkey=error").Parse(text)
+}
+
+func execute(text string, p Params) (string, error) {
+	tmpl, err := parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, p); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
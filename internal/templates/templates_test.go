@@ -0,0 +1,84 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lucasnevespereira/dashmin/internal/db"
+)
+
+func testSchema(name string) *db.Schema {
+	return &db.Schema{
+		Name: name,
+		Tables: []db.Table{
+			{Name: "users", Columns: []db.Column{{Name: "id"}, {Name: "created_at"}}},
+			{Name: "orders", Columns: []db.Column{{Name: "amount"}, {Name: "created_at"}}},
+		},
+	}
+}
+
+// builtin finds a built-in template, ignoring any user templates
+func builtin(t *testing.T, name string) Template {
+	t.Helper()
+	for _, tmpl := range builtins {
+		if tmpl.Name == name {
+			return tmpl
+		}
+	}
+	t.Fatalf("no built-in template %s", name)
+	return Template{}
+}
+
+func TestCheckSchema(t *testing.T) {
+	countToday := builtin(t, "count-today")
+
+	tests := []struct {
+		name   string
+		schema *db.Schema
+		params Params
+		want   string
+	}{
+		{"bare name", testSchema("public"), Params{Table: "users", DateColumn: "created_at"}, ""},
+		{"case-insensitive", testSchema("public"), Params{Table: "Users", DateColumn: "CREATED_AT"}, ""},
+		{"qualified name", testSchema("public"), Params{Table: "public.users", DateColumn: "created_at"}, ""},
+		{"qualified, other case", testSchema("public"), Params{Table: "PUBLIC.Users", DateColumn: "created_at"}, ""},
+		{"qualified, MySQL database", testSchema("shop"), Params{Table: "shop.orders", DateColumn: "created_at"}, ""},
+		{"qualified, missing column", testSchema("public"), Params{Table: "public.users", DateColumn: "signed_up_at"}, "column 'signed_up_at' not found in table 'users'"},
+		{"qualified, missing table", testSchema("public"), Params{Table: "public.events", DateColumn: "created_at"}, "table 'public.events' not found"},
+		{"other schema is not checked", testSchema("public"), Params{Table: "analytics.events", DateColumn: "at"}, ""},
+		{"missing table", testSchema("public"), Params{Table: "events", DateColumn: "created_at"}, "table 'events' not found. Available: users, orders"},
+		{"dotted collection", &db.Schema{Tables: []db.Table{{Name: "logs.errors"}}}, Params{Table: "logs.errors", DateColumn: "at"}, ""},
+		{"missing dotted collection", &db.Schema{Tables: []db.Table{{Name: "logs.errors"}}}, Params{Table: "logs.warnings", DateColumn: "at"}, "table 'logs.warnings' not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := countToday.CheckSchema(tt.schema, tt.params)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("CheckSchema: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CheckSchema: got error %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderQualifiedTable(t *testing.T) {
+	count := builtin(t, "count")
+	query, _, err := count.Render("postgres", Params{Table: "public.users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(query, "FROM public.users") {
+		t.Errorf("Render = %s, want it to select from public.users", query)
+	}
+
+	for _, table := range []string{"public.users.id", "users; DROP TABLE users", ".users", "public."} {
+		if _, _, err := count.Render("postgres", Params{Table: table}); err == nil {
+			t.Errorf("Render(%s): expected an invalid table error", table)
+		}
+	}
+}
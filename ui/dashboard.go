@@ -1,17 +1,16 @@
 package ui
 
 import (
+	"context"
 	"fmt"
-	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/lucasnevespereira/dashmin/internal/collector"
 	"github.com/lucasnevespereira/dashmin/internal/config"
-	"github.com/lucasnevespereira/dashmin/internal/db"
-	"golang.org/x/sync/errgroup"
+	"github.com/lucasnevespereira/dashmin/internal/history"
 )
 
 // Minimal color scheme
@@ -20,185 +19,310 @@ var (
 	green        = lipgloss.Color("#10b981")
 	red          = lipgloss.Color("#ef4444")
 	orange       = lipgloss.Color("#f97316")
+	yellow       = lipgloss.Color("#eab308")
 	gray         = lipgloss.Color("#6b7280")
 	white        = lipgloss.Color("#f9fafb")
 	titleStyle   = lipgloss.NewStyle().Foreground(white).Background(violet).Padding(0, 1).Bold(true)
 	successStyle = lipgloss.NewStyle().Foreground(green)
 	errorStyle   = lipgloss.NewStyle().Foreground(red)
 	timeoutStyle = lipgloss.NewStyle().Foreground(orange)
+	warningStyle = lipgloss.NewStyle().Foreground(yellow)
 	mutedStyle   = lipgloss.NewStyle().Foreground(gray)
 	modalStyle   = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1)
+	cursorStyle  = lipgloss.NewStyle().Foreground(violet).Bold(true)
 )
 
-type QueryResult struct {
-	AppName     string
-	QueryLabel  string
-	Result      *db.Result
-	LastUpdated time.Time
+// Options controls optional dashboard behaviour
+type Options struct {
+	// RefreshInterval enables watch mode when greater than zero. Queries
+	// with their own interval are refreshed on that instead.
+	RefreshInterval time.Duration
+	// History records every refresh when set
+	History *history.Store
+	// Layout is "table" (the default) or "grid"
+	Layout string
 }
 
+// tickMsg drives the scheduler and keeps ages and countdowns current
+type tickMsg time.Time
+
 type DashboardModel struct {
-	config       *config.Config
-	results      []QueryResult
-	loading      bool
-	lastRefresh  time.Time
-	error        error
-	filterApp    string
-	showErrors   bool
-	currentQuery string
+	ctx         context.Context
+	stop        context.CancelFunc
+	config      *config.Config
+	conns       *collector.Manager
+	history     *history.Store
+	results     []collector.QueryResult
+	loading     bool
+	lastRefresh time.Time
+	filterApp   string
+	showErrors  bool
+
+	// values tracks the last two successful values of each query so the
+	// dashboard can show what changed between refreshes
+	values map[queryKey]*trackedValue
+	// trends keeps the recent numeric values of each query for the sparkline
+	trends map[queryKey][]float64
+
+	// width and height are the terminal size, or 0 until the first
+	// WindowSizeMsg
+	width  int
+	height int
+
+	// selected is the key of the result under the cursor; keeping the key
+	// rather than a position keeps the selection across refreshes. detail
+	// holds the key of the result open in the detail pane, if any.
+	selected     queryKey
+	detail       queryKey
+	detailOffset int
+
+	// layout is "grid" for per-app panels of cards, otherwise a table.
+	// offset is the first line of the results shown when they are taller
+	// than the terminal.
+	layout string
+	offset int
+
+	// viewApp narrows the list to one app at runtime and search to results
+	// whose app or label fuzzy-match it
+	viewApp   string
+	search    string
+	searching bool
+
+	// pending marks queries whose new result has not arrived yet
+	pending   map[queryKey]bool
+	spinning  bool
+	spinFrame int
+
+	// runs holds the refreshes in flight by id. Several can overlap when
+	// queries with different intervals fall due.
+	runs  map[int]*run
+	runID int
+
+	// schedule decides which queries are due; while paused, nothing runs
+	// on its own
+	schedule *collector.Schedule
+	paused   bool
+	pausedAt time.Time
 }
 
-func NewDashboard(cfg *config.Config, filterApp string) *DashboardModel {
+func NewDashboard(ctx context.Context, cfg *config.Config, filterApp string, opts Options) *DashboardModel {
+	ctx, stop := context.WithCancel(ctx)
 	return &DashboardModel{
+		ctx:       ctx,
+		stop:      stop,
 		config:    cfg,
+		conns:     collector.NewManager(),
+		history:   opts.History,
 		filterApp: filterApp,
 		loading:   true,
+		values:    make(map[queryKey]*trackedValue),
+		trends:    make(map[queryKey][]float64),
+		pending:   make(map[queryKey]bool),
+		runs:      make(map[int]*run),
+		schedule:  collector.NewSchedule(cfg, filterApp, opts.RefreshInterval),
+		layout:    opts.Layout,
 	}
 }
 
 func (m *DashboardModel) Init() tea.Cmd {
-	return m.refreshData()
+	refresh := m.refreshData()
+	if m.history != nil {
+		// Seed the trends before the first results arrive
+		refresh = tea.Sequence(m.loadTrends(), refresh)
+	}
+	return tea.Batch(refresh, tick())
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+// watching reports whether any query refreshes on its own, from --watch or
+// a query interval
+func (m *DashboardModel) watching() bool {
+	return m.schedule.Scheduled()
+}
+
+// togglePause stops or resumes scheduled refreshes. Resuming postpones
+// every query by the time spent paused.
+func (m *DashboardModel) togglePause() {
+	if m.paused {
+		m.paused = false
+		m.schedule.Delay(time.Since(m.pausedAt))
+		return
+	}
+	m.paused = true
+	m.pausedAt = time.Now()
 }
 
 func (m *DashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.update(msg)
+	m.scrollToCursor()
+	return model, cmd
+}
+
+func (m *DashboardModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.detail != (queryKey{}) {
+			return m.updateDetail(msg)
+		}
+		if m.searching {
+			return m.updateSearch(msg)
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
-			return m, tea.Quit
+			return m, m.quit()
 		case "r":
-			m.loading = true
-			m.error = nil
 			m.showErrors = false
 			return m, m.refreshData()
+		case "R":
+			if r, ok := m.selectedResult(); ok {
+				return m, m.refreshQuery(r)
+			}
+		case "up", "k":
+			m.moveCursor(-1)
+		case "down", "j":
+			m.moveCursor(1)
+		case "enter":
+			m.openDetail()
+		case "/":
+			m.searching = true
+		case "esc":
+			m.search = ""
+			m.syncSelection()
+		case "tab":
+			m.cycleApp(1)
+		case "shift+tab":
+			m.cycleApp(-1)
+		case "p":
+			if m.watching() {
+				m.togglePause()
+			}
 		case "?":
-			if hasErrors(m.results) {
+			if collector.HasErrors(m.results) {
 				m.showErrors = !m.showErrors
 			}
 		}
-	case []QueryResult:
-		m.results = msg
-		m.loading = false
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.clampDetail()
+	case trendsMsg:
+		m.seedTrends(msg)
+	case tickMsg:
+		// Keep ticking so ages and countdowns stay current. Queries still
+		// running are not started again until they finish.
+		if !m.paused {
+			if due := m.dueQueries(time.Time(msg)); due.Len() > 0 {
+				return m, tea.Batch(m.startRun(due), tick())
+			}
+		}
+		return m, tick()
+	case resultMsg:
+		run, ok := m.runs[msg.id]
+		if !ok {
+			return m, nil
+		}
+		m.applyResult(run, msg.result)
+		m.clampDetail()
+		return m, m.waitForResult(msg.id, msg.results)
+	case refreshDoneMsg:
+		run, ok := m.runs[msg.id]
+		if !ok {
+			return m, nil
+		}
+		run.cancel()
+		delete(m.runs, msg.id)
+		m.finishRun(run)
+		m.clampDetail()
+		m.loading = len(m.runs) > 0
 		m.lastRefresh = time.Now()
-		m.error = nil
-		m.currentQuery = ""
-	case error:
-		m.loading = false
-		m.error = msg
-	case string:
-		// Progress update message
-		m.currentQuery = msg
+	case spinMsg:
+		m.spinFrame++
+		if len(m.pending) == 0 {
+			m.spinning = false
+			return m, nil
+		}
+		return m, spin()
 	}
 
 	return m, nil
 }
 
-func hasErrors(results []QueryResult) bool {
-	for _, r := range results {
-		if r.Result.Error != nil {
-			return true
-		}
-	}
-	return false
+// refreshData refreshes every query, aborting any refresh still running
+func (m *DashboardModel) refreshData() tea.Cmd {
+	m.cancelInFlight()
+	return m.startRun(collector.SelectAll(m.config, m.filterApp))
 }
 
-func (m *DashboardModel) refreshData() tea.Cmd {
-	return func() tea.Msg {
-		// Get sorted list of app names for deterministic ordering
-		var appNames []string
-		for appName := range m.config.Apps {
-			if m.filterApp != "" && appName != m.filterApp {
-				continue
+// dueQueries returns the scheduled queries due at now that are not already
+// running
+func (m *DashboardModel) dueQueries(now time.Time) collector.Selection {
+	due := make(collector.Selection)
+	for appName, labels := range m.schedule.Due(now) {
+		for _, label := range labels {
+			if !m.pending[resultKey(appName, label)] {
+				due[appName] = append(due[appName], label)
 			}
-			appNames = append(appNames, appName)
-		}
-		sort.Strings(appNames)
-
-		// Use errgroup for concurrent query execution
-		g := new(errgroup.Group)
-		var mu sync.Mutex
-		var allResults []QueryResult
-
-		for _, appName := range appNames {
-			app := m.config.Apps[appName]
-			g.Go(func() error {
-				appResults := queryApp(appName, app)
-				mu.Lock()
-				allResults = append(allResults, appResults...)
-				mu.Unlock()
-				return nil
-			})
-		}
-
-		if err := g.Wait(); err != nil {
-			return err
 		}
-
-		return allResults
 	}
+	return due
 }
 
-func queryApp(appName string, app config.App) []QueryResult {
-	conn, err := db.ConnectByType(app.Type, app.Connection)
-	if err != nil {
-		return []QueryResult{{
-			AppName:     appName,
-			QueryLabel:  "Connection",
-			Result:      &db.Result{Error: err},
-			LastUpdated: time.Now(),
-		}}
+// cancelInFlight aborts every running refresh
+func (m *DashboardModel) cancelInFlight() {
+	for _, run := range m.runs {
+		run.cancel()
 	}
-	defer func() { _ = conn.Close() }()
+	clear(m.runs)
+	clear(m.pending)
+	m.loading = false
+}
 
-	// Get sorted list of query labels for deterministic ordering
-	var queryLabels []string
-	for label := range app.Queries {
-		queryLabels = append(queryLabels, label)
+// alertLevel evaluates a result's value against its query's thresholds
+func alertLevel(r collector.QueryResult) config.AlertLevel {
+	val, ok := firstValue(r.Result)
+	if !ok {
+		return config.AlertNone
 	}
-	sort.Strings(queryLabels)
-
-	var results []QueryResult
-	for _, label := range queryLabels {
-		query := app.Queries[label]
-		result, err := conn.Query(query)
-		if err != nil {
-			result = &db.Result{Error: err}
-		}
-
-		results = append(results, QueryResult{
-			AppName:     appName,
-			QueryLabel:  label,
-			Result:      result,
-			LastUpdated: time.Now(),
-		})
+	f, ok := collector.ToFloat(val)
+	if !ok {
+		return config.AlertNone
 	}
-	return results
+	return r.Query.AlertLevel(f)
 }
 
-func formatValue(val interface{}) string {
-	switch v := val.(type) {
-	case int, int64:
-		return fmt.Sprintf("%d", v)
-	case float64, float32:
-		return fmt.Sprintf("%.2f", v)
-	case string:
-		if len(v) > 15 {
-			return v[:12] + "..."
+// alertSummary renders e.g. " • 2 critical, 1 warning", or nothing when all
+// values are within their thresholds
+func alertSummary(results []collector.QueryResult) string {
+	var critical, warning int
+	for _, r := range results {
+		switch alertLevel(r) {
+		case config.AlertCritical:
+			critical++
+		case config.AlertWarning:
+			warning++
 		}
-		return v
-	default:
-		return fmt.Sprintf("%v", val)
 	}
-}
 
-func isTimeoutError(err error) bool {
-	if err == nil {
-		return false
+	var parts []string
+	if critical > 0 {
+		parts = append(parts, errorStyle.Render(fmt.Sprintf("%d critical", critical)))
 	}
-	errStr := err.Error()
-	return strings.Contains(errStr, "timeout") ||
-		strings.Contains(errStr, "context deadline exceeded") ||
-		strings.Contains(errStr, "timed out")
+	if warning > 0 {
+		label := "warnings"
+		if warning == 1 {
+			label = "warning"
+		}
+		parts = append(parts, warningStyle.Render(fmt.Sprintf("%d %s", warning, label)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return mutedStyle.Render(" • ") + strings.Join(parts, mutedStyle.Render(", "))
 }
 
 func (m *DashboardModel) View() string {
@@ -206,27 +330,26 @@ func (m *DashboardModel) View() string {
 	if m.showErrors {
 		return m.renderErrorModal()
 	}
+	if m.detail != (queryKey{}) {
+		return m.renderDetail()
+	}
 
 	var b strings.Builder
+	var scrollPosition string
 
 	// Title with color
 	if m.filterApp != "" {
 		b.WriteString(titleStyle.Render(fmt.Sprintf("dashmin - %s", m.filterApp)))
+	} else if m.viewApp != "" {
+		b.WriteString(titleStyle.Render(fmt.Sprintf("dashmin - %s", m.viewApp)))
 	} else {
 		b.WriteString(titleStyle.Render("dashmin"))
 	}
 	b.WriteString("\n\n")
 
-	// Status with color
-	if m.loading {
-		if m.currentQuery != "" {
-			b.WriteString(mutedStyle.Render(fmt.Sprintf("Querying %s...", m.currentQuery)))
-		} else {
-			b.WriteString(mutedStyle.Render("Loading..."))
-		}
-		b.WriteString("\n\n")
-	} else if m.error != nil {
-		b.WriteString(errorStyle.Render(fmt.Sprintf("Error: %v", m.error)))
+	// Status with color. While refreshing, previous results stay visible.
+	if m.loading && len(m.results) == 0 {
+		b.WriteString(mutedStyle.Render("Loading..."))
 		b.WriteString("\n\n")
 	} else {
 		if len(m.results) == 0 {
@@ -242,60 +365,74 @@ func (m *DashboardModel) View() string {
 			} else {
 				b.WriteString(successStyle.Render(fmt.Sprintf("✓ %d apps, %d queries", len(m.config.Apps), len(m.results))))
 			}
-			b.WriteString(mutedStyle.Render(fmt.Sprintf(" • Updated %s", m.lastRefresh.Format("15:04:05"))))
+			if !m.lastRefresh.IsZero() {
+				b.WriteString(mutedStyle.Render(fmt.Sprintf(" • Updated %s", m.lastRefresh.Format("15:04:05"))))
+			}
+			b.WriteString(mutedStyle.Render(m.refreshStatus()))
+			b.WriteString(alertSummary(m.results))
 			b.WriteString("\n\n")
 
-			// Simple table with colored headers
-			headers := fmt.Sprintf("%-15s %-20s %-15s %s", "APP", "QUERY", "VALUE", "UPDATED")
-			b.WriteString(mutedStyle.Render(headers))
-			b.WriteString("\n")
-			b.WriteString(mutedStyle.Render(strings.Repeat("-", 70)))
-			b.WriteString("\n")
+			visible := m.visibleResults()
+			if m.searching || m.search != "" {
+				b.WriteString(m.searchLine(len(visible)))
+				b.WriteString("\n\n")
+			}
 
-			for _, result := range m.results {
-				var value, status string
-				var statusColor lipgloss.Style
-
-				if result.Result.Error != nil {
-					value = "ERROR"
-					if isTimeoutError(result.Result.Error) {
-						status = "⚠"
-						statusColor = timeoutStyle
-					} else {
-						status = "✗"
-						statusColor = errorStyle
-					}
-				} else if len(result.Result.Rows) > 0 && len(result.Result.Rows[0]) > 0 {
-					value = formatValue(result.Result.Rows[0][0])
-					status = "✓"
-					statusColor = successStyle
-				} else {
-					value = "No data"
-					status = "?"
-					statusColor = mutedStyle
-				}
-
-				b.WriteString(fmt.Sprintf("%s %-14s %-20s %-15s %s\n",
-					statusColor.Render(status),
-					result.AppName,
-					result.QueryLabel,
-					value,
-					mutedStyle.Render(result.LastUpdated.Format("15:04:05"))))
+			header, body, _, _ := m.renderResults(visible)
+			body, position := m.scrolled(header, body)
+			b.WriteString(strings.Join(append(header, body...), "\n"))
+			b.WriteString("\n")
+			if position != "" {
+				scrollPosition = " • " + position
 			}
 			b.WriteString("\n")
 		}
 	}
 
 	// Help with muted color
-	if hasErrors(m.results) {
-		b.WriteString(mutedStyle.Render("r: refresh, q: quit, ?: errors"))
-	} else {
-		b.WriteString(mutedStyle.Render("r: refresh, q: quit"))
-	}
+	help := m.helpText()
+	b.WriteString(mutedStyle.Render(help + scrollPosition))
 
 	return b.String()
 }
 
+// helpText lists the keys available on the results list
+func (m *DashboardModel) helpText() string {
+	help := "↑/↓: select, enter: details, /: search"
+	if m.filterApp == "" {
+		help += ", tab: app"
+	}
+	help += ", r: refresh, R: refresh query"
+	if m.watching() {
+		if m.paused {
+			help += ", p: resume"
+		} else {
+			help += ", p: pause"
+		}
+	}
+	help += ", q: quit"
+	if collector.HasErrors(m.results) {
+		help += ", ?: errors"
+	}
+	return help
+}
+
+// refreshStatus describes the in-flight refresh or the countdown to the
+// next scheduled query
+func (m *DashboardModel) refreshStatus() string {
+	soonest, scheduled := m.schedule.Soonest()
+	switch {
+	case m.loading:
+		return " • Refreshing..."
+	case !scheduled:
+		return ""
+	case m.paused:
+		return " • Paused"
+	default:
+		return fmt.Sprintf(" • Next refresh in %s", formatUntil(soonest))
+	}
+}
+
 func (m *DashboardModel) renderErrorModal() string {
 	var b strings.Builder
 
@@ -323,9 +460,17 @@ func (m *DashboardModel) renderErrorModal() string {
 	return modalStyle.Render(b.String())
 }
 
-func RunDashboard(cfg *config.Config, filterApp string) error {
-	m := NewDashboard(cfg, filterApp)
-	p := tea.NewProgram(m, tea.WithAltScreen())
+// Close aborts running queries and releases the database connections held
+// by the dashboard
+func (m *DashboardModel) Close() error {
+	m.stop()
+	return m.conns.Close()
+}
+
+func RunDashboard(ctx context.Context, cfg *config.Config, filterApp string, opts Options) error {
+	m := NewDashboard(ctx, cfg, filterApp, opts)
+	defer func() { _ = m.Close() }()
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx))
 	_, err := p.Run()
 	return err
 }
@@ -0,0 +1,253 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/db"
+	"github.com/mattn/go-runewidth"
+)
+
+// Limits for the compact tables shown inline for display: table queries
+const (
+	inlineTableRows  = 5
+	inlineCellWidth  = 20
+	defaultHeight    = 24
+	detailChromeRows = 4 // title, blank lines and help around the body
+)
+
+// detailResult returns the latest result of the query open in the detail
+// pane, so the pane follows refreshes
+func (m *DashboardModel) detailResult() (collector.QueryResult, bool) {
+	for _, r := range m.results {
+		if resultKey(r.AppName, r.QueryLabel) == m.detail {
+			return r, true
+		}
+	}
+	return collector.QueryResult{}, false
+}
+
+func (m *DashboardModel) openDetail() {
+	if r, ok := m.selectedResult(); ok {
+		m.detail = resultKey(r.AppName, r.QueryLabel)
+		m.detailOffset = 0
+	}
+}
+
+// updateDetail handles keys while the detail pane is open
+func (m *DashboardModel) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	page := m.detailBodyHeight()
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, m.quit()
+	case "esc", "enter":
+		m.detail = queryKey{}
+	case "up", "k":
+		m.detailOffset--
+	case "down", "j":
+		m.detailOffset++
+	case "pgup":
+		m.detailOffset -= page
+	case "pgdown", " ":
+		m.detailOffset += page
+	case "home", "g":
+		m.detailOffset = 0
+	case "end", "G":
+		m.detailOffset = len(m.detailLines())
+	}
+	m.clampDetail()
+	return m, nil
+}
+
+// clampDetail keeps the detail pane's scroll offset within its current
+// lines, which shrink when a refresh returns fewer rows or an error
+func (m *DashboardModel) clampDetail() {
+	if m.detail == (queryKey{}) {
+		return
+	}
+	m.detailOffset = max(0, min(m.detailOffset, len(m.detailLines())-m.detailBodyHeight()))
+}
+
+func (m *DashboardModel) detailBodyHeight() int {
+	height := m.height
+	if height == 0 {
+		height = defaultHeight
+	}
+	return max(1, height-detailChromeRows)
+}
+
+// detailLines renders everything the detail pane can scroll through
+func (m *DashboardModel) detailLines() []string {
+	r, ok := m.detailResult()
+	if !ok {
+		return []string{mutedStyle.Render("This query is no longer on the dashboard.")}
+	}
+
+	var lines []string
+	if r.Query.Description != "" {
+		lines = append(lines, mutedStyle.Render(r.Query.Description), "")
+	}
+	for i, line := range strings.Split(r.Query.Query, "\n") {
+		field := "          "
+		if i == 0 {
+			field = "Query:    "
+		}
+		lines = append(lines, mutedStyle.Render(field)+line)
+	}
+	if r.Query.Compare != "" {
+		lines = append(lines, mutedStyle.Render("Compare:  ")+r.Query.Compare)
+	}
+	lines = append(lines, mutedStyle.Render("Interval: ")+m.describeInterval(r))
+	if r.LastUpdated.IsZero() {
+		lines = append(lines, "", mutedStyle.Render("Waiting for the first result..."))
+		return lines
+	}
+	lines = append(lines,
+		mutedStyle.Render("Duration: ")+r.Duration.Round(time.Microsecond).String(),
+		mutedStyle.Render("Updated:  ")+r.LastUpdated.Format("2006-01-02 15:04:05"))
+	if r.Previous != nil {
+		previous := errorStyle.Render(fmt.Sprintf("%v", r.Previous.Error))
+		if r.Previous.Error == nil {
+			value, diff := formatComparison(r)
+			previous = strings.TrimSpace(fmt.Sprintf("%s (%s) %s", value, comparePeriod(r.Query), diff))
+		}
+		lines = append(lines, mutedStyle.Render("Previous: ")+previous)
+	}
+	lines = append(lines, "")
+
+	if r.Result.Error != nil {
+		lines = append(lines, errorStyle.Render(fmt.Sprintf("Error: %v", r.Result.Error)))
+		return lines
+	}
+	lines = append(lines, resultTable(r.Result, 0, 0)...)
+	rows := "rows"
+	if len(r.Result.Rows) == 1 {
+		rows = "row"
+	}
+	lines = append(lines, "", mutedStyle.Render(fmt.Sprintf("%d %s", len(r.Result.Rows), rows)))
+	return lines
+}
+
+// describeInterval renders how often a query refreshes, e.g. "every 15s,
+// next in 3s", or "manual" for queries refreshed on demand only
+func (m *DashboardModel) describeInterval(r collector.QueryResult) string {
+	interval := m.schedule.Interval(r.AppName, r.QueryLabel)
+	if interval == 0 {
+		return "manual (r or R to refresh)"
+	}
+	next, _ := m.schedule.Next(r.AppName, r.QueryLabel)
+	switch {
+	case m.pending[resultKey(r.AppName, r.QueryLabel)]:
+		return fmt.Sprintf("every %s, running now", interval)
+	case m.paused:
+		return fmt.Sprintf("every %s, paused", interval)
+	default:
+		return fmt.Sprintf("every %s, next in %s", interval, formatUntil(next))
+	}
+}
+
+func (m *DashboardModel) renderDetail() string {
+	var b strings.Builder
+
+	r, _ := m.detailResult()
+	b.WriteString(titleStyle.Render(fmt.Sprintf("%s / %s", r.AppName, r.QueryLabel)))
+	b.WriteString("\n\n")
+
+	lines := m.detailLines()
+	page := m.detailBodyHeight()
+	offset := max(0, min(m.detailOffset, len(lines)-page))
+	end := min(offset+page, len(lines))
+	for i, line := range lines[offset:end] {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if m.width > 0 {
+			// Wide tables are cut rather than wrapped so scrolling stays
+			// line based
+			line = ansi.Truncate(line, m.width, "…")
+		}
+		b.WriteString(line)
+	}
+	b.WriteString("\n\n")
+
+	help := "esc: back, q: quit"
+	if len(lines) > page {
+		help = fmt.Sprintf("↑/↓/pgup/pgdn: scroll (%d-%d of %d), %s", offset+1, end, len(lines), help)
+	}
+	b.WriteString(mutedStyle.Render(help))
+	return b.String()
+}
+
+// resultTable renders a result with column headers. maxRows and cellWidth
+// limit the output when greater than zero.
+func resultTable(result *db.Result, maxRows, cellWidth int) []string {
+	rows := result.Rows
+	if maxRows > 0 && len(rows) > maxRows {
+		rows = rows[:maxRows]
+	}
+
+	cells := make([][]string, len(rows))
+	widths := make([]int, len(result.Columns))
+	for i, col := range result.Columns {
+		widths[i] = runewidth.StringWidth(col)
+	}
+	for i, row := range rows {
+		cells[i] = make([]string, len(result.Columns))
+		for j := range result.Columns {
+			if j >= len(row) {
+				continue
+			}
+			cell := formatCell(row[j])
+			if cellWidth > 0 {
+				cell = truncate(cell, cellWidth)
+			}
+			cells[i][j] = cell
+			widths[j] = max(widths[j], runewidth.StringWidth(cell))
+		}
+	}
+
+	header := make([]string, len(result.Columns))
+	rule := make([]string, len(result.Columns))
+	for i, col := range result.Columns {
+		if cellWidth > 0 {
+			col = truncate(col, cellWidth)
+			widths[i] = min(widths[i], cellWidth)
+		}
+		header[i] = runewidth.FillRight(col, widths[i])
+		rule[i] = strings.Repeat("-", widths[i])
+	}
+
+	lines := []string{
+		mutedStyle.Render(strings.Join(header, "  ")),
+		mutedStyle.Render(strings.Join(rule, "  ")),
+	}
+	for _, row := range cells {
+		padded := make([]string, len(row))
+		for i, cell := range row {
+			padded[i] = runewidth.FillRight(cell, widths[i])
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(padded, "  "), " "))
+	}
+	return lines
+}
+
+// inlineTable renders the compact top-N table shown under a display: table
+// query
+func inlineTable(r collector.QueryResult) []string {
+	if r.Result.Error != nil || len(r.Result.Columns) == 0 {
+		return nil
+	}
+
+	var lines []string
+	for _, line := range resultTable(r.Result, inlineTableRows, inlineCellWidth) {
+		lines = append(lines, "    "+line)
+	}
+	if more := len(r.Result.Rows) - inlineTableRows; more > 0 {
+		lines = append(lines, mutedStyle.Render(fmt.Sprintf("    … %d more (enter for details)", more)))
+	}
+	return lines
+}
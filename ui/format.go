@@ -0,0 +1,176 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/mattn/go-runewidth"
+)
+
+func formatDelta(delta float64) string {
+	if delta == math.Trunc(delta) {
+		return fmt.Sprintf("%+d", int64(delta))
+	}
+	return fmt.Sprintf("%+.2f", delta)
+}
+
+// formatValue renders a value using the query's format and unit
+func formatValue(val interface{}, q config.Query) string {
+	value := formatRaw(val, q.Format)
+	if q.Unit != "" {
+		value += " " + q.Unit
+	}
+	return value
+}
+
+func formatRaw(val interface{}, format string) string {
+	if f, ok := collector.ToFloat(val); ok && format != "" {
+		switch format {
+		case "number":
+			return formatNumber(f)
+		case "percent":
+			return fmt.Sprintf("%.1f%%", f)
+		case "bytes":
+			return formatBytes(f)
+		case "duration":
+			return formatSeconds(f)
+		default:
+			return fmt.Sprintf(format, f)
+		}
+	}
+
+	switch v := val.(type) {
+	case int, int64:
+		return fmt.Sprintf("%d", v)
+	case float64, float32:
+		return fmt.Sprintf("%.2f", v)
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// formatCell renders a value in full, as shown in result tables
+func formatCell(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// truncate shortens s to width terminal cells, ending with an ellipsis when
+// anything was cut. Multibyte and wide characters are never split.
+func truncate(s string, width int) string {
+	return runewidth.Truncate(s, width, "…")
+}
+
+// fitCell truncates or pads s to exactly width terminal cells, flattening
+// line breaks so a cell never spans rows
+func fitCell(s string, width int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	return runewidth.FillRight(truncate(s, width), width)
+}
+
+// formatAge renders how long ago a result was updated, e.g. "12s ago", or
+// nothing for a query that has not returned yet
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return compactDuration(time.Since(t)) + " ago"
+}
+
+// formatUntil renders the time left until t, e.g. "12s", or "0s" once it
+// has passed
+func formatUntil(t time.Time) string {
+	return compactDuration(time.Until(t))
+}
+
+// compactDuration renders d in its largest whole unit, e.g. 90s -> 1m, so
+// it fits a narrow column
+func compactDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", max(0, int(d.Round(time.Second)/time.Second)))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+}
+
+// formatNumber adds thousands separators, e.g. 1234567 -> 1,234,567
+func formatNumber(f float64) string {
+	s := strconv.FormatFloat(math.Abs(f), 'f', -1, 64)
+	if f != math.Trunc(f) {
+		s = strconv.FormatFloat(math.Abs(f), 'f', 2, 64)
+	}
+	intPart, frac, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if f < 0 {
+		b.WriteString("-")
+	}
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(",")
+		}
+		b.WriteRune(c)
+	}
+	if frac != "" {
+		b.WriteString("." + frac)
+	}
+	return b.String()
+}
+
+// formatBytes renders a byte count using binary units
+func formatBytes(f float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	i := 0
+	for math.Abs(f) >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", f, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", f, units[i])
+}
+
+// formatSeconds renders a number of seconds as a duration, e.g. 90 -> 1m30s
+func formatSeconds(f float64) string {
+	d := time.Duration(f * float64(time.Second))
+	if d >= time.Second {
+		d = d.Round(time.Second)
+	}
+	return d.String()
+}
+
+func isTimeoutError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	errStr := err.Error()
+	return strings.Contains(errStr, "timeout") ||
+		strings.Contains(errStr, "context deadline exceeded") ||
+		strings.Contains(errStr, "timed out")
+}
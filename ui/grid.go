@@ -0,0 +1,230 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/mattn/go-runewidth"
+)
+
+// Grid layout sizes, in terminal cells
+const (
+	minPanelWidth    = 44
+	defaultGridWidth = 80
+	panelChrome      = 4 // border and horizontal padding
+	cardChrome       = 4
+)
+
+// cardSize describes how much a card shows for each Query.Size
+type cardSize struct {
+	width int // including border
+	lines int // content lines: label, value, then change and trend
+}
+
+var cardSizes = map[string]cardSize{
+	"small":  {width: 18, lines: 2},
+	"medium": {width: 24, lines: 3},
+	"large":  {width: 32, lines: 4},
+}
+
+var (
+	panelTitleStyle = lipgloss.NewStyle().Foreground(violet).Bold(true)
+	groupStyle      = lipgloss.NewStyle().Foreground(gray).Bold(true)
+)
+
+func sizeOf(q config.Query) cardSize {
+	if size, ok := cardSizes[q.Size]; ok {
+		return size
+	}
+	return cardSizes["medium"]
+}
+
+// renderGrid renders each app as a panel of cards, with panels wrapping
+// into as many columns as the terminal fits
+func (m *DashboardModel) renderGrid(visible []collector.QueryResult) (body []string, selStart, selEnd int) {
+	var apps []string
+	byApp := make(map[string][]collector.QueryResult)
+	for _, r := range visible {
+		if _, ok := byApp[r.AppName]; !ok {
+			apps = append(apps, r.AppName)
+		}
+		byApp[r.AppName] = append(byApp[r.AppName], r)
+	}
+	if len(apps) == 0 {
+		return nil, 0, 0
+	}
+
+	width := m.width
+	if width == 0 {
+		width = defaultGridWidth
+	}
+	cols := max(1, min(width/minPanelWidth, len(apps)))
+	panelWidth := width / cols
+
+	var selected queryKey
+	if len(visible) > 0 {
+		r := visible[m.cursorIndex(visible)]
+		selected = resultKey(r.AppName, r.QueryLabel)
+	}
+
+	for start := 0; start < len(apps); start += cols {
+		row := apps[start:min(start+cols, len(apps))]
+
+		contents := make([]string, len(row))
+		height := 0
+		hasSelected := false
+		for i, app := range row {
+			contents[i] = m.panelContent(app, byApp[app], panelWidth-panelChrome, selected)
+			height = max(height, lipgloss.Height(contents[i]))
+			hasSelected = hasSelected || selected.app == app
+		}
+
+		panels := make([]string, len(row))
+		for i, app := range row {
+			panels[i] = lipgloss.NewStyle().
+				Border(lipgloss.RoundedBorder()).
+				BorderForeground(panelColor(byApp[app])).
+				Padding(0, 1).
+				Width(panelWidth - 2).
+				Height(height).
+				Render(contents[i])
+		}
+
+		lines := strings.Split(lipgloss.JoinHorizontal(lipgloss.Top, panels...), "\n")
+		if hasSelected {
+			selStart, selEnd = len(body), len(body)+len(lines)
+		}
+		body = append(body, lines...)
+	}
+	return body, selStart, selEnd
+}
+
+// panelContent renders an app's title and its cards, ungrouped cards first
+// and then each group under its heading
+func (m *DashboardModel) panelContent(app string, results []collector.QueryResult, width int, selected queryKey) string {
+	var groups []string
+	byGroup := make(map[string][]string)
+	for _, r := range results {
+		group := r.Query.Group
+		if _, ok := byGroup[group]; !ok && group != "" {
+			groups = append(groups, group)
+		}
+		card := m.renderCard(r, resultKey(r.AppName, r.QueryLabel) == selected)
+		byGroup[group] = append(byGroup[group], card)
+	}
+
+	sections := []string{panelTitleStyle.Render(truncate(app, width))}
+	if cards := byGroup[""]; len(cards) > 0 {
+		sections = append(sections, flow(cards, width))
+	}
+	for _, group := range groups {
+		sections = append(sections, groupStyle.Render(truncate(group, width)), flow(byGroup[group], width))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, sections...)
+}
+
+// renderCard renders a big-number card: the label, the value with its unit
+// and, on larger cards, the change and trend
+func (m *DashboardModel) renderCard(r collector.QueryResult, selected bool) string {
+	size := sizeOf(r.Query)
+	inner := size.width - cardChrome
+	row := m.tableRow(r)
+
+	label := r.QueryLabel
+	if m.pending[resultKey(r.AppName, r.QueryLabel)] {
+		label = m.spinner() + " " + label
+	}
+
+	value := row.valueStyle.Bold(true).Render(truncate(row.value, inner))
+	if r.Result.Error == nil && r.Query.Display != "table" && len(r.Result.Rows) > 0 && len(r.Result.Rows[0]) > 0 && r.Query.Unit != "" {
+		// Keep the unit visible and muted next to the number
+		number := truncate(formatRaw(r.Result.Rows[0][0], r.Query.Format), max(1, inner-runewidth.StringWidth(r.Query.Unit)-1))
+		value = row.valueStyle.Bold(true).Render(number) + " " + mutedStyle.Render(r.Query.Unit)
+	}
+
+	lines := []string{mutedStyle.Render(truncate(label, inner)), value}
+	if size.lines > 2 {
+		detail := row.change
+		if row.previous != "" {
+			// On a status board the comparison says more than the last change
+			detail = strings.TrimSpace(fmt.Sprintf("%s vs %s %s", row.diff, row.previous, comparePeriod(r.Query)))
+		}
+		if r.Result.Error != nil {
+			detail = r.Result.Error.Error()
+		}
+		lines = append(lines, mutedStyle.Render(truncate(detail, inner)))
+	}
+	if size.lines > 3 {
+		trend := ""
+		if r.Query.Display != "table" {
+			trend = m.formatTrend(r, inner)
+		}
+		lines = append(lines, mutedStyle.Render(trend))
+	}
+
+	border := gray
+	switch {
+	case selected:
+		border = violet
+	case isTimeoutError(r.Result.Error):
+		border = orange
+	case r.Result.Error != nil:
+		border = red
+	case alertLevel(r) == config.AlertCritical:
+		border = red
+	case alertLevel(r) == config.AlertWarning:
+		border = yellow
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(border).
+		Padding(0, 1).
+		Width(size.width - 2).
+		Render(strings.Join(lines, "\n"))
+}
+
+// panelColor colors an app's panel border by its worst result
+func panelColor(results []collector.QueryResult) lipgloss.Color {
+	color := gray
+	for _, r := range results {
+		if r.Result.Error != nil {
+			return red
+		}
+		switch alertLevel(r) {
+		case config.AlertCritical:
+			return red
+		case config.AlertWarning:
+			color = yellow
+		}
+	}
+	return color
+}
+
+// flow lays blocks out left to right, wrapping onto a new row when the
+// next block would not fit in width
+func flow(blocks []string, width int) string {
+	var rows []string
+	var row []string
+	rowWidth := 0
+	for _, block := range blocks {
+		w := lipgloss.Width(block)
+		if len(row) > 0 && rowWidth+1+w > width {
+			rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+			row, rowWidth = nil, 0
+		}
+		if len(row) > 0 {
+			row = append(row, " ")
+			rowWidth++
+		}
+		row = append(row, block)
+		rowWidth += w
+	}
+	if len(row) > 0 {
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
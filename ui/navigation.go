@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+)
+
+// searchLine shows the search being typed or applied
+func (m *DashboardModel) searchLine(matches int) string {
+	line := "/" + m.search
+	if m.searching {
+		line += "█"
+	}
+	return line + mutedStyle.Render(fmt.Sprintf("  %d of %d queries (esc to clear)", matches, len(m.results)))
+}
+
+// visibleResults returns the results matching the app filter and search
+func (m *DashboardModel) visibleResults() []collector.QueryResult {
+	if m.viewApp == "" && m.search == "" {
+		return m.results
+	}
+	var visible []collector.QueryResult
+	for _, r := range m.results {
+		if m.viewApp != "" && r.AppName != m.viewApp {
+			continue
+		}
+		if !fuzzyMatch(m.search, r.AppName+"/"+r.QueryLabel) {
+			continue
+		}
+		visible = append(visible, r)
+	}
+	return visible
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in s in
+// order, ignoring case. Spaces in the pattern are ignored.
+func fuzzyMatch(pattern, s string) bool {
+	target := []rune(strings.ToLower(s))
+	i := 0
+	for _, c := range strings.ToLower(pattern) {
+		if unicode.IsSpace(c) {
+			continue
+		}
+		for i < len(target) && target[i] != c {
+			i++
+		}
+		if i == len(target) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// cursorIndex locates the selected result among the visible ones. When it
+// is filtered out or gone, the first visible result is selected instead.
+func (m *DashboardModel) cursorIndex(visible []collector.QueryResult) int {
+	for i, r := range visible {
+		if resultKey(r.AppName, r.QueryLabel) == m.selected {
+			return i
+		}
+	}
+	return 0
+}
+
+// selectedResult returns the result under the cursor
+func (m *DashboardModel) selectedResult() (collector.QueryResult, bool) {
+	visible := m.visibleResults()
+	if len(visible) == 0 {
+		return collector.QueryResult{}, false
+	}
+	return visible[m.cursorIndex(visible)], true
+}
+
+// syncSelection moves the selection to the result the cursor falls back to
+// once the selected one is filtered out
+func (m *DashboardModel) syncSelection() {
+	m.moveCursor(0)
+}
+
+func (m *DashboardModel) moveCursor(delta int) {
+	visible := m.visibleResults()
+	if len(visible) == 0 {
+		return
+	}
+	i := max(0, min(m.cursorIndex(visible)+delta, len(visible)-1))
+	m.selected = resultKey(visible[i].AppName, visible[i].QueryLabel)
+}
+
+// cycleApp switches the app shown, going through every app and then back to
+// all of them. It does nothing when the dashboard was started for one app.
+func (m *DashboardModel) cycleApp(delta int) {
+	if m.filterApp != "" {
+		return
+	}
+	apps := append([]string{""}, collector.AppNames(m.config, "")...)
+	i := slices.Index(apps, m.viewApp)
+	m.viewApp = apps[(i+delta+len(apps))%len(apps)]
+	m.syncSelection()
+}
+
+// updateSearch handles keys while typing a search
+func (m *DashboardModel) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, m.quit()
+	case tea.KeyEsc:
+		m.search = ""
+		m.searching = false
+	case tea.KeyEnter:
+		m.searching = false
+	case tea.KeyBackspace:
+		if r := []rune(m.search); len(r) > 0 {
+			m.search = string(r[:len(r)-1])
+		}
+	case tea.KeyUp:
+		m.moveCursor(-1)
+	case tea.KeyDown:
+		m.moveCursor(1)
+	case tea.KeyRunes, tea.KeySpace:
+		m.search += string(msg.Runes)
+	}
+	m.syncSelection()
+	return m, nil
+}
+
+// refreshQuery re-runs only the given result's query. For a connection
+// failure, every query of the app is retried.
+func (m *DashboardModel) refreshQuery(r collector.QueryResult) tea.Cmd {
+	app, ok := m.config.Apps[r.AppName]
+	if !ok {
+		return nil
+	}
+	labels := []string{r.QueryLabel}
+	if r.QueryLabel == collector.ConnectionLabel {
+		labels = app.SortedLabels()
+	} else if _, ok := app.Queries[r.QueryLabel]; !ok {
+		return nil
+	}
+	return m.startRun(collector.Selection{r.AppName: labels})
+}
+
+// quit aborts all running queries and exits the dashboard
+func (m *DashboardModel) quit() tea.Cmd {
+	m.cancelInFlight()
+	m.stop()
+	return tea.Quit
+}
@@ -0,0 +1,41 @@
+package ui
+
+import (
+	"math"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []float64
+		width  int
+		want   string
+	}{
+		{nil, 10, ""},
+		{[]float64{1, 2, 3}, 0, ""},
+		{[]float64{0, 7}, 10, "▁█"},
+		{[]float64{5, 5, 5}, 10, "▅▅▅"},
+		{[]float64{0, 1, 2, 3, 4, 5, 6, 7}, 3, "▁▄█"},
+	}
+	for _, tt := range tests {
+		if got := sparkline(tt.values, tt.width); got != tt.want {
+			t.Errorf("sparkline(%v, %d) = %q, want %q", tt.values, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestSparklineNonFinite(t *testing.T) {
+	for _, values := range [][]float64{
+		{1, math.Inf(1), 3},
+		{math.Inf(-1), 2, 3},
+		{math.Inf(-1), math.Inf(1)},
+		{1, math.NaN(), 3},
+		{math.NaN()},
+	} {
+		got := sparkline(values, 10)
+		if n := utf8.RuneCountInString(got); n != len(values) {
+			t.Errorf("sparkline(%v) = %q, want %d levels", values, got, len(values))
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package ui
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/db"
+)
+
+// run is a refresh in flight: the queries it was started for and whether
+// each has delivered a result yet
+type run struct {
+	cancel   context.CancelFunc
+	expected map[queryKey]bool
+}
+
+// resultMsg delivers one result of a refresh as soon as its query finishes
+type resultMsg struct {
+	id      int
+	result  collector.QueryResult
+	results <-chan collector.QueryResult
+}
+
+// refreshDoneMsg reports that every query of a refresh has finished.
+// Messages of a refresh that was aborted are recognized by their id and
+// dropped.
+type refreshDoneMsg struct {
+	id int
+}
+
+// spinMsg advances the spinner shown on pending rows
+type spinMsg struct{}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+const spinInterval = 100 * time.Millisecond
+
+// startRun runs the selected queries, streaming their results into the
+// dashboard as they finish
+func (m *DashboardModel) startRun(sel collector.Selection) tea.Cmd {
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.runID++
+	id := m.runID
+	r := &run{cancel: cancel, expected: make(map[queryKey]bool)}
+	m.runs[id] = r
+	m.loading = true
+	m.schedule.Ran(sel, time.Now())
+
+	for _, appName := range collector.AppNames(m.config, m.filterApp) {
+		if labels, ok := sel[appName]; ok {
+			m.expectPending(r, appName, labels)
+		}
+	}
+
+	results := make(chan collector.QueryResult)
+	go collector.Stream(ctx, m.config, sel, m.conns, results)
+	return tea.Batch(m.waitForResult(id, results), m.startSpinner())
+}
+
+// waitForResult reads the next result of a refresh, recording it in the
+// history store on the way
+func (m *DashboardModel) waitForResult(id int, results <-chan collector.QueryResult) tea.Cmd {
+	store := m.history
+	return func() tea.Msg {
+		r, ok := <-results
+		if !ok {
+			return refreshDoneMsg{id: id}
+		}
+		if store != nil {
+			// History is best effort and never interrupts the dashboard
+			_ = store.Record([]collector.QueryResult{r})
+		}
+		return resultMsg{id: id, result: r, results: results}
+	}
+}
+
+// expectPending marks every query about to run as pending. Queries not on
+// the dashboard yet get a placeholder row so their spinner shows right away.
+func (m *DashboardModel) expectPending(run *run, appName string, labels []string) {
+	for _, label := range labels {
+		key := resultKey(appName, label)
+		m.pending[key] = true
+		run.expected[key] = false
+		if !slices.ContainsFunc(m.results, func(r collector.QueryResult) bool {
+			return resultKey(r.AppName, r.QueryLabel) == key
+		}) {
+			m.insertResult(collector.QueryResult{
+				AppName:    appName,
+				QueryLabel: label,
+				Query:      m.config.Apps[appName].Queries[label],
+				Result:     &db.Result{},
+			})
+		}
+	}
+}
+
+// applyResult shows a fresh result in place of the previous one for the
+// same query
+func (m *DashboardModel) applyResult(run *run, r collector.QueryResult) {
+	key := resultKey(r.AppName, r.QueryLabel)
+	run.expected[key] = true
+	m.trackValues([]collector.QueryResult{r})
+
+	if r.QueryLabel == collector.ConnectionLabel {
+		// None of the app's queries in this run ran; stop waiting for them
+		for k := range run.expected {
+			if k.app == r.AppName && !m.awaited(k, run) {
+				delete(m.pending, k)
+			}
+		}
+	} else {
+		if !m.awaited(key, run) {
+			delete(m.pending, key)
+		}
+		// The app is reachable again
+		m.results = slices.DeleteFunc(m.results, func(old collector.QueryResult) bool {
+			return old.AppName == r.AppName && old.QueryLabel == collector.ConnectionLabel
+		})
+	}
+
+	for i, old := range m.results {
+		if resultKey(old.AppName, old.QueryLabel) == key {
+			m.results[i] = r
+			return
+		}
+	}
+	m.insertResult(r)
+}
+
+// insertResult adds a result at its place in dashboard order: by app name,
+// then by query order, with connection failures first
+func (m *DashboardModel) insertResult(r collector.QueryResult) {
+	i := slices.IndexFunc(m.results, func(old collector.QueryResult) bool {
+		return m.before(r, old)
+	})
+	if i < 0 {
+		i = len(m.results)
+	}
+	m.results = slices.Insert(m.results, i, r)
+}
+
+// before reports whether a comes before b in the order collector.Collect
+// returns results
+func (m *DashboardModel) before(a, b collector.QueryResult) bool {
+	if a.AppName != b.AppName {
+		return a.AppName < b.AppName
+	}
+	return m.labelRank(a) < m.labelRank(b)
+}
+
+func (m *DashboardModel) labelRank(r collector.QueryResult) int {
+	if r.QueryLabel == collector.ConnectionLabel {
+		return -1
+	}
+	labels := m.config.Apps[r.AppName].SortedLabels()
+	if i := slices.Index(labels, r.QueryLabel); i >= 0 {
+		return i
+	}
+	return len(labels)
+}
+
+// finishRun drops the rows a completed refresh did not deliver, such as
+// queries of an app that could not connect, unless another refresh still
+// runs them
+func (m *DashboardModel) finishRun(run *run) {
+	m.results = slices.DeleteFunc(m.results, func(r collector.QueryResult) bool {
+		key := resultKey(r.AppName, r.QueryLabel)
+		delivered, expected := run.expected[key]
+		return expected && !delivered && !m.awaited(key, run)
+	})
+}
+
+// awaited reports whether a refresh other than the given one still waits
+// for a query's result
+func (m *DashboardModel) awaited(key queryKey, except *run) bool {
+	for _, other := range m.runs {
+		if other != except {
+			if delivered, expected := other.expected[key]; expected && !delivered {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// startSpinner ticks the spinner while queries are pending
+func (m *DashboardModel) startSpinner() tea.Cmd {
+	if m.spinning || len(m.pending) == 0 {
+		return nil
+	}
+	m.spinning = true
+	return spin()
+}
+
+func spin() tea.Cmd {
+	return tea.Tick(spinInterval, func(time.Time) tea.Msg {
+		return spinMsg{}
+	})
+}
+
+// spinner returns the current spinner frame
+func (m *DashboardModel) spinner() string {
+	return spinnerFrames[m.spinFrame%len(spinnerFrames)]
+}
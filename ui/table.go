@@ -0,0 +1,320 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/mattn/go-runewidth"
+)
+
+// Column width bounds, in terminal cells. Columns grow to fit their content
+// up to the maximum and shrink towards the minimum on narrow terminals.
+const (
+	minAppWidth    = 8
+	maxAppWidth    = 24
+	minLabelWidth  = 10
+	maxLabelWidth  = 40
+	minValueWidth  = 10
+	maxValueWidth  = 24
+	minChangeWidth = 6
+	maxChangeWidth = 24
+	ageWidth       = 8 // e.g. "12m ago"
+	nextWidth      = 8 // e.g. "in 30s"
+	rowPrefixWidth = 4 // cursor marker and status icon, each followed by a space
+)
+
+// Lines around the results besides their header and the help: title,
+// status line and blank lines
+const listChromeRows = 5
+
+// diffWidth fits a comparison such as "+123.4%"
+const diffWidth = 8
+
+// columns holds the width of each results table column. A zero trend or
+// previous width hides the trend or the comparison columns.
+type columns struct {
+	app, label, value, previous, trend, change int
+}
+
+// total returns the width of a full table row
+func (c columns) total() int {
+	w := rowPrefixWidth + c.app + 1 + c.label + 1 + c.value + 1 + c.change + 1 + ageWidth + 1 + nextWidth
+	if c.previous > 0 {
+		w += c.previous + 1 + diffWidth + 1
+	}
+	if c.trend > 0 {
+		w += c.trend + 1
+	}
+	return w
+}
+
+// tableRow is a results table row before it is laid out
+type tableRow struct {
+	result      collector.QueryResult
+	status      string
+	statusStyle lipgloss.Style
+	value       string
+	valueStyle  lipgloss.Style
+	previous    string
+	diff        string
+	change      string
+}
+
+func (m *DashboardModel) tableRow(result collector.QueryResult) tableRow {
+	row := tableRow{result: result, valueStyle: lipgloss.NewStyle()}
+
+	switch {
+	case result.Result.Error != nil:
+		row.value = "ERROR"
+		if isTimeoutError(result.Result.Error) {
+			row.status, row.statusStyle = "⚠", timeoutStyle
+		} else {
+			row.status, row.statusStyle = "✗", errorStyle
+		}
+	case result.Query.Display == "table":
+		row.value = fmt.Sprintf("%d rows", len(result.Result.Rows))
+		row.status, row.statusStyle = "✓", successStyle
+	case len(result.Result.Rows) > 0 && len(result.Result.Rows[0]) > 0:
+		row.value = formatValue(result.Result.Rows[0][0], result.Query)
+		switch alertLevel(result) {
+		case config.AlertCritical:
+			row.status, row.statusStyle = "!", errorStyle
+			row.valueStyle = errorStyle.Bold(true)
+		case config.AlertWarning:
+			row.status, row.statusStyle = "!", warningStyle
+			row.valueStyle = warningStyle
+		default:
+			row.status, row.statusStyle = "✓", successStyle
+		}
+	default:
+		row.value = "No data"
+		row.status, row.statusStyle = "?", mutedStyle
+	}
+
+	if m.pending[resultKey(result.AppName, result.QueryLabel)] {
+		// Keep the previous value until the new one arrives
+		row.status, row.statusStyle = m.spinner(), mutedStyle
+		if result.LastUpdated.IsZero() {
+			row.value = ""
+		}
+	}
+
+	// Tables have no single value to compare
+	if result.Query.Display != "table" {
+		row.change = m.formatChange(result)
+		row.previous, row.diff = formatComparison(result)
+	}
+	return row
+}
+
+// layoutColumns sizes the columns to their content, then fits them to the
+// terminal width: spare room goes to the trend column, and when space runs
+// out the trend is hidden and the widest columns shrink first
+func (m *DashboardModel) layoutColumns(rows []tableRow) columns {
+	c := columns{
+		app:    runewidth.StringWidth("APP"),
+		label:  runewidth.StringWidth("QUERY"),
+		value:  runewidth.StringWidth("VALUE"),
+		change: runewidth.StringWidth("CHANGE"),
+	}
+	for _, r := range rows {
+		c.app = max(c.app, runewidth.StringWidth(r.result.AppName))
+		c.label = max(c.label, runewidth.StringWidth(r.result.QueryLabel))
+		c.value = max(c.value, runewidth.StringWidth(r.value))
+		c.change = max(c.change, runewidth.StringWidth(r.change))
+		if r.result.Query.Compare != "" {
+			c.previous = max(c.previous, runewidth.StringWidth("PREVIOUS"), runewidth.StringWidth(r.previous))
+		}
+	}
+	if c.previous > 0 {
+		c.value = max(c.value, runewidth.StringWidth("CURRENT"))
+	}
+	c.app = min(c.app, maxAppWidth)
+	c.label = min(c.label, maxLabelWidth)
+	c.value = min(c.value, maxValueWidth)
+	c.previous = min(c.previous, maxValueWidth)
+	c.change = min(c.change, maxChangeWidth)
+
+	if m.width == 0 {
+		c.trend = defaultTrendWidth
+		return c
+	}
+
+	if spare := m.width - c.total() - 1; spare >= minTrendWidth {
+		c.trend = min(spare, maxTrendWidth)
+		return c
+	}
+
+	overflow := c.total() - m.width
+	for _, col := range []struct {
+		width *int
+		min   int
+	}{
+		{&c.label, minLabelWidth},
+		{&c.app, minAppWidth},
+		{&c.value, minValueWidth},
+		{&c.previous, minValueWidth},
+		{&c.change, minChangeWidth},
+	} {
+		if overflow <= 0 {
+			break
+		}
+		shrink := min(overflow, max(0, *col.width-col.min))
+		*col.width -= shrink
+		overflow -= shrink
+	}
+	return c
+}
+
+// formatNext describes when a query runs next: "in 12s", "running", or "-"
+// for queries that only refresh on demand
+func (m *DashboardModel) formatNext(r collector.QueryResult) string {
+	if m.pending[resultKey(r.AppName, r.QueryLabel)] {
+		return "running"
+	}
+	next, scheduled := m.schedule.Next(r.AppName, r.QueryLabel)
+	switch {
+	case !scheduled || r.QueryLabel == collector.ConnectionLabel:
+		return "-"
+	case m.paused:
+		return "paused"
+	default:
+		return "in " + formatUntil(next)
+	}
+}
+
+// renderHeader renders the column headers and the rule under them
+func renderHeader(c columns) []string {
+	header := strings.Repeat(" ", rowPrefixWidth) +
+		fitCell("APP", c.app) + " " +
+		fitCell("QUERY", c.label) + " "
+	if c.previous > 0 {
+		header += fitCell("CURRENT", c.value) + " " +
+			fitCell("PREVIOUS", c.previous) + " " +
+			fitCell("DIFF%", diffWidth) + " "
+	} else {
+		header += fitCell("VALUE", c.value) + " "
+	}
+	if c.trend > 0 {
+		header += fitCell("TREND", c.trend) + " "
+	}
+	header += fitCell("CHANGE", c.change) + " " + fitCell("AGE", ageWidth) + " " + "NEXT"
+
+	return []string{
+		mutedStyle.Render(header),
+		mutedStyle.Render(strings.Repeat("-", c.total())),
+	}
+}
+
+// renderRow renders a row followed by its inline table, if any. Cells are
+// padded before styling so color codes don't break alignment.
+func (m *DashboardModel) renderRow(row tableRow, c columns, selected bool) []string {
+	marker := " "
+	if selected {
+		marker = cursorStyle.Render("›")
+	}
+
+	line := marker + " " + row.statusStyle.Render(row.status) + " " +
+		fitCell(row.result.AppName, c.app) + " " +
+		fitCell(row.result.QueryLabel, c.label) + " " +
+		row.valueStyle.Render(fitCell(row.value, c.value)) + " "
+	if c.previous > 0 {
+		line += mutedStyle.Render(fitCell(row.previous, c.previous)) + " " +
+			fitCell(row.diff, diffWidth) + " "
+	}
+	if c.trend > 0 {
+		trend := ""
+		if row.result.Query.Display != "table" {
+			trend = m.formatTrend(row.result, c.trend)
+		}
+		line += mutedStyle.Render(fitCell(trend, c.trend)) + " "
+	}
+	line += fitCell(row.change, c.change) + " " +
+		mutedStyle.Render(fitCell(formatAge(row.result.LastUpdated), ageWidth)) + " " +
+		mutedStyle.Render(m.formatNext(row.result))
+
+	lines := []string{line}
+	if row.result.Query.Display == "table" {
+		lines = append(lines, inlineTable(row.result)...)
+	}
+	return lines
+}
+
+// listHeight is the number of lines available to the results below their
+// header, or 0 when the terminal size is unknown and nothing is scrolled
+func (m *DashboardModel) listHeight(headerLines int) int {
+	if m.height == 0 {
+		return 0
+	}
+	// The help wraps on narrow terminals; leave room for the scroll position
+	helpWidth := runewidth.StringWidth(m.helpText()) + len(" • lines 100-100 of 100")
+	chrome := listChromeRows + headerLines + (helpWidth+m.width-1)/max(m.width, 1)
+	if m.searching || m.search != "" {
+		chrome += 2
+	}
+	return max(1, m.height-chrome)
+}
+
+// renderResults renders the visible results in the current layout. It
+// returns a header that stays on screen, the scrollable body and the body
+// lines taken by the selected result.
+func (m *DashboardModel) renderResults(visible []collector.QueryResult) (header, body []string, selStart, selEnd int) {
+	if m.layout == "grid" {
+		body, selStart, selEnd = m.renderGrid(visible)
+		return nil, body, selStart, selEnd
+	}
+	return m.renderList(visible)
+}
+
+// scrollToCursor adjusts the scroll offset so the selected result is on
+// screen
+func (m *DashboardModel) scrollToCursor() {
+	header, body, start, end := m.renderResults(m.visibleResults())
+	height := m.listHeight(len(header))
+	if height == 0 {
+		m.offset = 0
+		return
+	}
+
+	if end > m.offset+height {
+		m.offset = end - height
+	}
+	if start < m.offset {
+		m.offset = start
+	}
+	m.offset = max(0, min(m.offset, len(body)-height))
+}
+
+// scrolled cuts the body to the lines on screen. It also returns a
+// description of the scroll position when the body does not fit.
+func (m *DashboardModel) scrolled(header, body []string) ([]string, string) {
+	height := m.listHeight(len(header))
+	if height == 0 || len(body) <= height {
+		return body, ""
+	}
+	start := min(m.offset, len(body)-height)
+	end := start + height
+	return body[start:end], fmt.Sprintf("lines %d-%d of %d", start+1, end, len(body))
+}
+
+// renderList renders the visible results as a table
+func (m *DashboardModel) renderList(visible []collector.QueryResult) (header, body []string, selStart, selEnd int) {
+	rows := make([]tableRow, len(visible))
+	for i, r := range visible {
+		rows[i] = m.tableRow(r)
+	}
+	c := m.layoutColumns(rows)
+
+	cursor := m.cursorIndex(visible)
+	for i, row := range rows {
+		lines := m.renderRow(row, c, i == cursor)
+		if i == cursor {
+			selStart, selEnd = len(body), len(body)+len(lines)
+		}
+		body = append(body, lines...)
+	}
+	return renderHeader(c), body, selStart, selEnd
+}
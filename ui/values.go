@@ -0,0 +1,219 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/lucasnevespereira/dashmin/internal/collector"
+	"github.com/lucasnevespereira/dashmin/internal/config"
+	"github.com/lucasnevespereira/dashmin/internal/db"
+)
+
+// maxTrendSamples caps how many numeric samples are kept per query for the
+// trend column
+const maxTrendSamples = 60
+
+// trendWindow is how far back the trend is seeded from the history store
+const trendWindow = 24 * time.Hour
+
+// Trend column width bounds, in characters
+const (
+	defaultTrendWidth = 10
+	minTrendWidth     = 5
+	maxTrendWidth     = 30
+)
+
+// trendsMsg delivers trend samples loaded from the history store
+type trendsMsg map[queryKey][]float64
+
+// trackedValue remembers the last two successful values of a query
+type trackedValue struct {
+	previous    interface{}
+	hasPrevious bool
+	current     interface{}
+}
+
+// queryKey identifies a query across refreshes. It is a struct rather than
+// a joined string, since app names and labels may contain any separator.
+type queryKey struct {
+	app, label string
+}
+
+func resultKey(appName, label string) queryKey {
+	return queryKey{app: appName, label: label}
+}
+
+// firstValue returns the single value displayed for a result
+func firstValue(result *db.Result) (interface{}, bool) {
+	if result == nil || result.Error != nil || len(result.Rows) == 0 || len(result.Rows[0]) == 0 {
+		return nil, false
+	}
+	return result.Rows[0][0], true
+}
+
+// trackValues records successful values. Failed queries leave their history
+// untouched so the change is computed against the last good value once the
+// query recovers.
+func (m *DashboardModel) trackValues(results []collector.QueryResult) {
+	for _, r := range results {
+		val, ok := firstValue(r.Result)
+		if !ok {
+			continue
+		}
+		key := resultKey(r.AppName, r.QueryLabel)
+		if f, numeric := collector.ToFloat(val); numeric {
+			m.trends[key] = appendSample(m.trends[key], f)
+		}
+		tv, exists := m.values[key]
+		if !exists {
+			m.values[key] = &trackedValue{current: val}
+			continue
+		}
+		tv.previous = tv.current
+		tv.hasPrevious = true
+		tv.current = val
+	}
+}
+
+// formatChange describes how a value moved since the previous refresh
+func (m *DashboardModel) formatChange(r collector.QueryResult) string {
+	val, ok := firstValue(r.Result)
+	if !ok {
+		return ""
+	}
+	tv, exists := m.values[resultKey(r.AppName, r.QueryLabel)]
+	if !exists || !tv.hasPrevious {
+		return ""
+	}
+
+	cur, curOK := collector.ToFloat(val)
+	prev, prevOK := collector.ToFloat(tv.previous)
+	if !curOK || !prevOK {
+		if fmt.Sprintf("%v", val) == fmt.Sprintf("%v", tv.previous) {
+			return "same"
+		}
+		return "changed"
+	}
+
+	delta := cur - prev
+	if delta == 0 {
+		return "= 0"
+	}
+
+	arrow := "↑"
+	if delta < 0 {
+		arrow = "↓"
+	}
+	change := fmt.Sprintf("%s %s", arrow, formatDelta(delta))
+	if prev != 0 {
+		change += fmt.Sprintf(" (%+.1f%%)", delta/math.Abs(prev)*100)
+	}
+	return change
+}
+
+// formatComparison renders a result's previous-period value and how far the
+// current value is from it, e.g. "38" and "+18.4%"
+func formatComparison(r collector.QueryResult) (previous, diff string) {
+	if r.Previous == nil {
+		return "", ""
+	}
+	if r.Previous.Error != nil {
+		return "ERROR", ""
+	}
+	prev, ok := firstValue(r.Previous)
+	if !ok {
+		return "No data", ""
+	}
+	previous = formatValue(prev, r.Query)
+
+	val, ok := firstValue(r.Result)
+	if !ok {
+		return previous, ""
+	}
+	cur, curOK := collector.ToFloat(val)
+	before, prevOK := collector.ToFloat(prev)
+	switch {
+	case !curOK || !prevOK:
+		return previous, ""
+	case before == 0 && cur == 0:
+		return previous, "0%"
+	case before == 0:
+		return previous, "new"
+	default:
+		return previous, fmt.Sprintf("%+.1f%%", (cur-before)/math.Abs(before)*100)
+	}
+}
+
+// comparePeriod names what a query is compared with, e.g. "yesterday"
+func comparePeriod(q config.Query) string {
+	switch q.Compare {
+	case "yesterday":
+		return "yesterday"
+	case "last_week":
+		return "last week"
+	default:
+		return "previous"
+	}
+}
+
+func appendSample(samples []float64, v float64) []float64 {
+	samples = append(samples, v)
+	if len(samples) > maxTrendSamples {
+		samples = samples[len(samples)-maxTrendSamples:]
+	}
+	return samples
+}
+
+// loadTrends seeds the trend column with recent values from the history
+// store so a freshly opened dashboard already shows where numbers are going
+func (m *DashboardModel) loadTrends() tea.Cmd {
+	store := m.history
+	cfg := m.config
+	filterApp := m.filterApp
+	return func() tea.Msg {
+		trends := make(trendsMsg)
+		since := time.Now().Add(-trendWindow)
+		for _, appName := range collector.AppNames(cfg, filterApp) {
+			for _, label := range cfg.Apps[appName].SortedLabels() {
+				samples, err := store.Samples(appName, label, since)
+				if err != nil {
+					// History is best effort; start without a trend
+					return trends
+				}
+				var values []float64
+				for _, s := range samples {
+					if s.Value != nil {
+						values = appendSample(values, *s.Value)
+					}
+				}
+				if len(values) > 0 {
+					trends[resultKey(appName, label)] = values
+				}
+			}
+		}
+		return trends
+	}
+}
+
+// seedTrends prepends loaded samples to any collected since the dashboard
+// started
+func (m *DashboardModel) seedTrends(trends trendsMsg) {
+	for key, samples := range trends {
+		for _, v := range m.trends[key] {
+			samples = appendSample(samples, v)
+		}
+		m.trends[key] = samples
+	}
+}
+
+// formatTrend renders the sparkline of a result, or nothing until there are
+// at least two samples
+func (m *DashboardModel) formatTrend(r collector.QueryResult, width int) string {
+	samples := m.trends[resultKey(r.AppName, r.QueryLabel)]
+	if len(samples) < 2 {
+		return ""
+	}
+	return sparkline(samples, width)
+}